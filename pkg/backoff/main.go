@@ -0,0 +1,86 @@
+package backoff
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+type StrategyType string
+
+const (
+	// StrategyLegacy waits base seconds squared times the retry, e.g. 25s, 50s, 75s for a base of 5s
+	StrategyLegacy StrategyType = "legacy"
+	// StrategyConstant waits base on every retry
+	StrategyConstant StrategyType = "constant"
+	// StrategyLinear waits base times the retry
+	StrategyLinear StrategyType = "linear"
+	// StrategyExponential waits base times factor to the power of the previous retries
+	StrategyExponential StrategyType = "exponential"
+	// StrategyDecorrelatedJitter waits a random duration between base and three times the upper bound of the previous retry
+	StrategyDecorrelatedJitter StrategyType = "decorrelatedJitter"
+)
+
+// MaxDuration is the longest wait the scheduler framework allows at permit, longer durations are capped to it
+const MaxDuration = 15 * time.Minute
+
+// Strategy calculates how long a pod waits based on how many times it had to wait already
+type Strategy struct {
+	Type   StrategyType
+	Base   time.Duration
+	Factor float64
+	// Max caps the wait duration, 0 disables the cap
+	Max time.Duration
+}
+
+func (s Strategy) Validate() error {
+	switch s.Type {
+	case "", StrategyLegacy, StrategyConstant, StrategyLinear, StrategyDecorrelatedJitter:
+	case StrategyExponential:
+		if s.Factor < 1 {
+			return fmt.Errorf("backoff strategy %s requires a factor of at least 1", s.Type)
+		}
+	default:
+		return fmt.Errorf("unknown backoff strategy %s", s.Type)
+	}
+
+	if s.Max < 0 {
+		return fmt.Errorf("backoff max must not be negative")
+	}
+	return nil
+}
+
+// Duration returns the wait duration for the retry, the first wait is retry 1
+func (s Strategy) Duration(retry int) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+
+	var seconds float64
+	base := s.Base.Seconds()
+	switch s.Type {
+	case StrategyConstant:
+		seconds = base
+	case StrategyLinear:
+		seconds = base * float64(retry)
+	case StrategyExponential:
+		seconds = base * math.Pow(s.Factor, float64(retry-1))
+	case StrategyDecorrelatedJitter:
+		// the previous wait is not known, therefore its upper bound is used
+		upper := s.capSeconds(base * math.Pow(3, float64(retry-1)))
+		seconds = base + rand.Float64()*(upper-base)
+	default:
+		seconds = math.Pow(base, 2) * float64(retry)
+	}
+
+	return time.Duration(s.capSeconds(seconds) * float64(time.Second))
+}
+
+// capSeconds applies the configured cap and MaxDuration, the latter prevents growing strategies from overflowing time.Duration
+func (s Strategy) capSeconds(seconds float64) float64 {
+	if s.Max > 0 && seconds > s.Max.Seconds() {
+		seconds = s.Max.Seconds()
+	}
+	return min(seconds, MaxDuration.Seconds())
+}
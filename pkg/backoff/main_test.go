@@ -0,0 +1,130 @@
+package backoff
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	testcases := []struct {
+		name     string
+		strategy Strategy
+		expected []time.Duration
+	}{
+		{
+			name:     "legacy",
+			strategy: Strategy{Type: StrategyLegacy, Base: 5 * time.Second},
+			expected: []time.Duration{25 * time.Second, 50 * time.Second, 75 * time.Second},
+		},
+		{
+			name:     "legacy by default",
+			strategy: Strategy{Base: 5 * time.Second},
+			expected: []time.Duration{25 * time.Second, 50 * time.Second, 75 * time.Second},
+		},
+		{
+			name:     "constant",
+			strategy: Strategy{Type: StrategyConstant, Base: 5 * time.Second},
+			expected: []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:     "linear",
+			strategy: Strategy{Type: StrategyLinear, Base: 5 * time.Second},
+			expected: []time.Duration{5 * time.Second, 10 * time.Second, 15 * time.Second},
+		},
+		{
+			name:     "exponential",
+			strategy: Strategy{Type: StrategyExponential, Base: 5 * time.Second, Factor: 2},
+			expected: []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second},
+		},
+		{
+			name:     "exponential with cap",
+			strategy: Strategy{Type: StrategyExponential, Base: 5 * time.Second, Factor: 2, Max: 15 * time.Second},
+			expected: []time.Duration{5 * time.Second, 10 * time.Second, 15 * time.Second, 15 * time.Second},
+		},
+		{
+			name:     "legacy with cap",
+			strategy: Strategy{Type: StrategyLegacy, Base: 5 * time.Second, Max: 60 * time.Second},
+			expected: []time.Duration{25 * time.Second, 50 * time.Second, 60 * time.Second},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, expected := range tc.expected {
+				assert.Equal(t, expected, tc.strategy.Duration(i+1), "retry %d", i+1)
+			}
+		})
+	}
+}
+
+func TestDurationOfLargeRetries(t *testing.T) {
+	testcases := []struct {
+		name     string
+		strategy Strategy
+		retry    int
+		expected time.Duration
+	}{
+		{
+			name:     "exponential",
+			strategy: Strategy{Type: StrategyExponential, Base: 5 * time.Second, Factor: 2},
+			retry:    40,
+			expected: MaxDuration,
+		},
+		{
+			name:     "exponential beyond float range",
+			strategy: Strategy{Type: StrategyExponential, Base: 5 * time.Second, Factor: 2},
+			retry:    5000,
+			expected: MaxDuration,
+		},
+		{
+			name:     "exponential with cap",
+			strategy: Strategy{Type: StrategyExponential, Base: 5 * time.Second, Factor: 2, Max: 2 * time.Minute},
+			retry:    40,
+			expected: 2 * time.Minute,
+		},
+		{
+			name:     "legacy",
+			strategy: Strategy{Type: StrategyLegacy, Base: 5 * time.Second},
+			retry:    1000,
+			expected: MaxDuration,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.strategy.Duration(tc.retry))
+		})
+	}
+
+	jitter := Strategy{Type: StrategyDecorrelatedJitter, Base: 2 * time.Second}
+	for i := 0; i < 100; i++ {
+		d := jitter.Duration(1000)
+		assert.GreaterOrEqual(t, d, 2*time.Second)
+		assert.LessOrEqual(t, d, MaxDuration)
+	}
+}
+
+func TestDecorrelatedJitterDuration(t *testing.T) {
+	strategy := Strategy{Type: StrategyDecorrelatedJitter, Base: 2 * time.Second, Max: 30 * time.Second}
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 2*time.Second, strategy.Duration(1))
+
+		d := strategy.Duration(2)
+		assert.GreaterOrEqual(t, d, 2*time.Second)
+		assert.LessOrEqual(t, d, 6*time.Second)
+
+		d = strategy.Duration(5)
+		assert.GreaterOrEqual(t, d, 2*time.Second)
+		assert.LessOrEqual(t, d, 30*time.Second)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Strategy{}.Validate())
+	assert.NoError(t, Strategy{Type: StrategyExponential, Factor: 1.5}.Validate())
+	assert.EqualError(t, Strategy{Type: StrategyExponential, Factor: 0.5}.Validate(), "backoff strategy exponential requires a factor of at least 1")
+	assert.EqualError(t, Strategy{Type: "fibonacci"}.Validate(), "unknown backoff strategy fibonacci")
+	assert.EqualError(t, Strategy{Type: StrategyConstant, Max: -time.Second}.Validate(), "backoff max must not be negative")
+}
@@ -0,0 +1,112 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s.io/klog/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Path serves the state of all registered profiles, the query parameters profile and node limit it to a single profile or node
+const Path = "/debug/thundering-herd"
+
+// StateFunc returns the json serializable state of a profile, limited to the node if the node name is not empty
+type StateFunc func(nodeName string) interface{}
+
+// Server serves the state of the registered profiles, every address is listened on only once
+type Server struct {
+	profiles  map[string]StateFunc
+	listeners map[string]net.Listener
+	lock      *sync.Mutex
+}
+
+var defaultServer = NewServer()
+
+func NewServer() *Server {
+	var lock sync.Mutex
+	return &Server{
+		profiles:  make(map[string]StateFunc),
+		listeners: make(map[string]net.Listener),
+		lock:      &lock,
+	}
+}
+
+// Register adds the state of a profile to the default server and starts listening on the address if not done yet
+func Register(address string, profile string, state StateFunc) error {
+	return defaultServer.Register(address, profile, state)
+}
+
+// Register adds the state of a profile and starts listening on the address if not done yet,
+// the profiles of all scheduler profiles are served on every address
+func (s *Server) Register(address string, profile string, state StateFunc) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.listeners[address]; ok {
+		s.profiles[profile] = state
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on debug address %s: %v", address, err)
+	}
+	s.listeners[address] = listener
+	s.profiles[profile] = state
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		klog.InfoS("Serving debug endpoint", "address", listener.Addr().String(), "path", Path)
+		if err := server.Serve(listener); err != nil {
+			klog.ErrorS(err, "Debug endpoint stopped", "address", address)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile := r.URL.Query().Get("profile")
+	nodeName := r.URL.Query().Get("node")
+	profiles := s.selectProfiles(profile)
+	if profile != "" && len(profiles) == 0 {
+		http.Error(w, fmt.Sprintf("unknown profile %s", profile), http.StatusNotFound)
+		return
+	}
+
+	// the state is collected without holding the lock as it reads the informer caches
+	ret := make(map[string]interface{}, len(profiles))
+	for name, state := range profiles {
+		ret[name] = state(nodeName)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(ret); err != nil {
+		klog.ErrorS(err, "Failed to write debug state")
+	}
+}
+
+// selectProfiles returns all profiles, or only the given one if the name is not empty
+func (s *Server) selectProfiles(name string) map[string]StateFunc {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ret := make(map[string]StateFunc, len(s.profiles))
+	for profile, state := range s.profiles {
+		if name == "" || profile == name {
+			ret[profile] = state
+		}
+	}
+	return ret
+}
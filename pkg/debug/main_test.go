@@ -0,0 +1,99 @@
+package debug
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShouldServeStateOfProfiles(t *testing.T) {
+	s := NewServer()
+	s.profiles["profile-1"] = func(nodeName string) interface{} {
+		return map[string]string{"node": nodeName}
+	}
+	s.profiles["profile-2"] = func(_ string) interface{} {
+		return "state"
+	}
+
+	testcases := []struct {
+		name           string
+		method         string
+		query          string
+		expectedStatus int
+		expected       map[string]interface{}
+	}{
+		{
+			name:           "all profiles",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expected: map[string]interface{}{
+				"profile-1": map[string]interface{}{"node": ""},
+				"profile-2": "state",
+			},
+		},
+		{
+			name:           "single profile and node",
+			method:         http.MethodGet,
+			query:          "?profile=profile-1&node=node-1",
+			expectedStatus: http.StatusOK,
+			expected: map[string]interface{}{
+				"profile-1": map[string]interface{}{"node": "node-1"},
+			},
+		},
+		{
+			name:           "unknown profile",
+			method:         http.MethodGet,
+			query:          "?profile=unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "post",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(tc.method, Path+tc.query, nil))
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			if tc.expected != nil {
+				assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+				var actual map[string]interface{}
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &actual))
+				assert.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestShouldListenOncePerAddress(t *testing.T) {
+	s := NewServer()
+	assert.NoError(t, s.Register("127.0.0.1:0", "profile-1", func(_ string) interface{} {
+		return "state-1"
+	}))
+	listener := s.listeners["127.0.0.1:0"]
+	t.Cleanup(func() { _ = listener.Close() })
+
+	// a second profile on the same address is served by the existing listener
+	assert.NoError(t, s.Register("127.0.0.1:0", "profile-2", func(_ string) interface{} {
+		return "state-2"
+	}))
+	assert.Len(t, s.listeners, 1)
+
+	resp, err := http.Get("http://" + listener.Addr().String() + Path)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var actual map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&actual))
+	assert.Equal(t, map[string]interface{}{"profile-1": "state-1", "profile-2": "state-2"}, actual)
+
+	assert.Error(t, s.Register("invalid", "profile-3", func(_ string) interface{} {
+		return nil
+	}))
+	assert.NotContains(t, s.profiles, "profile-3")
+}
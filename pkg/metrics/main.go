@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"sync"
+)
+
+const subsystem = "thundering_herd"
+
+var (
+	// FailurePolicyApplied counts how often the failure policy was applied per failed dependency
+	FailurePolicyApplied = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      subsystem,
+			Name:           "failure_policy_applied_total",
+			Help:           "Number of times the failure policy was applied because of a failed dependency, by source and policy.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"source", "policy"})
+
+	// PermitDecisions counts the decisions of the permit extension point, in shadow mode the decision which would have been taken
+	PermitDecisions = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      subsystem,
+			Name:           "permit_decisions_total",
+			Help:           "Number of permit decisions by result and mode, in shadow mode pods are admitted regardless of the result.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"result", "mode"})
+
+	// WaitDuration observes the wait durations pods got at permit
+	WaitDuration = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Subsystem:      subsystem,
+			Name:           "wait_duration_seconds",
+			Help:           "Wait duration in seconds a throttled pod got at permit.",
+			Buckets:        metrics.ExponentialBuckets(1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		})
+
+	// WaitedDuration observes how long waiting pods waited until a starting slot was granted
+	WaitedDuration = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Subsystem:      subsystem,
+			Name:           "waited_duration_seconds",
+			Help:           "Duration in seconds a waiting pod waited until a starting slot was granted.",
+			Buckets:        metrics.ExponentialBuckets(1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		})
+
+	// Retries observes the retry counter of throttled pods
+	Retries = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Subsystem:      subsystem,
+			Name:           "retries",
+			Help:           "Retry counter of pods throttled at permit.",
+			Buckets:        metrics.LinearBuckets(1, 1, 10),
+			StabilityLevel: metrics.ALPHA,
+		})
+
+	// ForceAdmissions counts pods scheduled without free starting slot
+	ForceAdmissions = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      subsystem,
+			Name:           "force_admissions_total",
+			Help:           "Number of pods scheduled without free starting slot, by reason maxRetries or maxTotalWait.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"reason"})
+
+	// NodeStartingPods is the number of not ready pods per node when its budget was checked last
+	NodeStartingPods = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      subsystem,
+			Name:           "node_starting_pods",
+			Help:           "Number of not ready pods on the node when its startup budget was checked last.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"node"})
+
+	// NodeAllowedStartingPods is the number of pods allowed to start in parallel per node when its budget was checked last
+	NodeAllowedStartingPods = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      subsystem,
+			Name:           "node_allowed_starting_pods",
+			Help:           "Number of pods allowed to start in parallel on the node when its startup budget was checked last.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"node"})
+
+	// DependencyErrors counts the failed informer cache lookups of the node state and the failed patches of the pod counter
+	DependencyErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      subsystem,
+			Name:           "dependency_errors_total",
+			Help:           "Number of failed informer cache lookups by source nodestate and failed pod patches by source podcounter.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"source"})
+
+	registerMetrics sync.Once
+)
+
+// DeleteNode removes the series of a deleted node, otherwise its last values are reported forever
+func DeleteNode(nodeName string) {
+	NodeStartingPods.DeleteLabelValues(nodeName)
+	NodeAllowedStartingPods.DeleteLabelValues(nodeName)
+}
+
+// Register registers the metrics with the legacy registry served by the scheduler, multiple calls register them only once
+func Register() {
+	registerMetrics.Do(func() {
+		legacyregistry.MustRegister(FailurePolicyApplied)
+		legacyregistry.MustRegister(PermitDecisions)
+		legacyregistry.MustRegister(WaitDuration)
+		legacyregistry.MustRegister(WaitedDuration)
+		legacyregistry.MustRegister(Retries)
+		legacyregistry.MustRegister(ForceAdmissions)
+		legacyregistry.MustRegister(NodeStartingPods)
+		legacyregistry.MustRegister(NodeAllowedStartingPods)
+		legacyregistry.MustRegister(DependencyErrors)
+	})
+}
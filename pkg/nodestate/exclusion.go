@@ -0,0 +1,118 @@
+package nodestate
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
+// container waiting reasons of a pod which is not starting, but backing off
+var backOffReasons = map[string]bool{
+	"CrashLoopBackOff": true,
+	"ImagePullBackOff": true,
+}
+
+// isPodExcluded reports if a not started pod shouldn't block other pods from starting anymore
+func (n *NodeStateV3) isPodExcluded(pod *v1.Pod) bool {
+	if n.options.ExcludeBackOff && isPodInBackOff(pod) {
+		return true
+	}
+	if n.options.MaxStartingDuration > 0 {
+		since := n.startingSince(pod)
+		return !since.IsZero() && n.clock.Since(since) > n.options.MaxStartingDuration
+	}
+	return false
+}
+
+// watchStartingDuration notifies the handlers once a starting pod exceeded the max starting duration,
+// no informer event is sent when a pod stops counting by just getting older
+func (n *NodeStateV3) watchStartingDuration(pod *v1.Pod) {
+	if n.options.MaxStartingDuration <= 0 || !n.IsPodStarting(pod) {
+		return
+	}
+	since := n.startingSince(pod)
+	if since.IsZero() {
+		return
+	}
+
+	podKey := podStoringKey(pod)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if _, ok := n.startingTimers[podKey]; ok {
+		return
+	}
+	// the pod is excluded as soon as it's not started for longer than the max starting duration
+	remaining := n.options.MaxStartingDuration - n.clock.Since(since) + time.Nanosecond
+	n.startingTimers[podKey] = n.clock.AfterFunc(remaining, func() {
+		n.onStartingDurationExceeded(pod)
+	})
+}
+
+func (n *NodeStateV3) stopWatchingStartingDuration(pod *v1.Pod) {
+	podKey := podStoringKey(pod)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if timer, ok := n.startingTimers[podKey]; ok {
+		timer.Stop()
+		delete(n.startingTimers, podKey)
+	}
+}
+
+func (n *NodeStateV3) onStartingDurationExceeded(pod *v1.Pod) {
+	n.lock.Lock()
+	delete(n.startingTimers, podStoringKey(pod))
+	n.lock.Unlock()
+
+	obj, exists, err := n.podIndexer.Get(pod)
+	if err != nil || !exists {
+		return
+	}
+	latest, ok := obj.(*v1.Pod)
+	if !ok || latest.UID != pod.UID {
+		return
+	}
+
+	// the pod became ready and not ready again in the meantime
+	if n.IsPodStarting(latest) {
+		n.watchStartingDuration(latest)
+		return
+	}
+	if n.isPodExcluded(latest) {
+		n.notifyPodStarted(latest, latest.Spec.NodeName)
+	}
+}
+
+// startingSince returns when the pod became not started, based on the condition of the started signal if available
+func (n *NodeStateV3) startingSince(pod *v1.Pod) time.Time {
+	conditionType := n.options.StartedSignal.conditionType()
+	for _, c := range pod.Status.Conditions {
+		if c.Type == conditionType && !c.LastTransitionTime.IsZero() {
+			return c.LastTransitionTime.Time
+		}
+	}
+	if pod.Status.StartTime != nil {
+		return pod.Status.StartTime.Time
+	}
+	return pod.CreationTimestamp.Time
+}
+
+func (n *NodeStateV3) isPodRestarting(pod *v1.Pod) bool {
+	for _, c := range pod.Status.ContainerStatuses {
+		terminated := c.LastTerminationState.Terminated
+		if c.RestartCount > 0 && terminated != nil && n.clock.Since(terminated.FinishedAt.Time) <= n.options.RestartingWindow {
+			return true
+		}
+	}
+	return false
+}
+
+func isPodInBackOff(pod *v1.Pod) bool {
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.State.Waiting != nil && backOffReasons[c.State.Waiting.Reason] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package nodestate
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShouldExcludeLongUnreadyAndBackOffPods(t *testing.T) {
+	c := clock.NewMock()
+	c.Set(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	young := mockUnhealthyPod("young", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+	young.Status.Conditions = []v1.PodCondition{mockNotReadyCondition(c.Now().Add(-5 * time.Minute))}
+	old := mockUnhealthyPod("old", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-1")
+	old.Status.Conditions = []v1.PodCondition{mockNotReadyCondition(c.Now().Add(-2 * time.Hour))}
+	oldWithoutCondition := mockUnhealthyPod("old-without-condition", "ns-1", "9a2a4b63-35b4-4e0c-a6cb-c9ce0a3c1b0e", "node-1")
+	oldWithoutCondition.Status.StartTime = &meta_v1.Time{Time: c.Now().Add(-2 * time.Hour)}
+	crashing := mockUnhealthyPod("crashing", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1")
+	crashing.Status.Conditions = []v1.PodCondition{mockNotReadyCondition(c.Now().Add(-5 * time.Minute))}
+	crashing.Status.ContainerStatuses = []v1.ContainerStatus{{
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+	pods := []v1.Pod{young, old, oldWithoutCondition, crashing}
+
+	testcases := []struct {
+		name     string
+		options  Options
+		expected int
+	}{
+		{
+			name:     "no exclusion",
+			options:  Options{DefaultStartupCost: 1},
+			expected: 4,
+		},
+		{
+			name:     "max starting duration",
+			options:  Options{DefaultStartupCost: 1, MaxStartingDuration: time.Hour},
+			expected: 2,
+		},
+		{
+			name:     "back-off",
+			options:  Options{DefaultStartupCost: 1, ExcludeBackOff: true},
+			expected: 3,
+		},
+		{
+			name:     "both",
+			options:  Options{DefaultStartupCost: 1, MaxStartingDuration: time.Hour, ExcludeBackOff: true},
+			expected: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			stateV3 := newTestNodeStateWithOptions(t, tc.options, c, pods, nil)
+			notReadyPods, err := stateV3.NotReadyPods("node-1")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, notReadyPods)
+		})
+	}
+}
+
+func TestShouldWeightRestartingPods(t *testing.T) {
+	c := clock.NewMock()
+	c.Set(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	restarted := mockUnhealthyPod("restarted", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+	restarted.Status.ContainerStatuses = []v1.ContainerStatus{mockRestartedContainer(c.Now().Add(-time.Minute))}
+	restartedLongAgo := mockUnhealthyPod("restarted-long-ago", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-1")
+	restartedLongAgo.Status.ContainerStatuses = []v1.ContainerStatus{mockRestartedContainer(c.Now().Add(-time.Hour))}
+	pods := []v1.Pod{restarted, restartedLongAgo}
+
+	testcases := []struct {
+		name     string
+		options  Options
+		expected float64
+	}{
+		{
+			name:     "restarting weight disabled",
+			options:  Options{DefaultStartupCost: 1, RestartingWindow: 5 * time.Minute},
+			expected: 2,
+		},
+		{
+			name:     "restarting weight",
+			options:  Options{DefaultStartupCost: 1, RestartingStartupCost: ptr.To(0.25), RestartingWindow: 5 * time.Minute},
+			expected: 1.25,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			stateV3 := newTestNodeStateWithOptions(t, tc.options, c, pods, nil)
+			cost, err := stateV3.NotReadyPodsCost("node-1")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, cost)
+		})
+	}
+}
+
+func TestShouldNotifyHandlersWhenPodExceededMaxStartingDuration(t *testing.T) {
+	c := clock.NewMock()
+	c.Set(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	starting := mockUnhealthyPod("starting", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+	starting.Status.Conditions = []v1.PodCondition{mockNotReadyCondition(c.Now().Add(-30 * time.Minute))}
+	stateV3 := newTestNodeStateWithOptions(t, Options{DefaultStartupCost: 1, MaxStartingDuration: time.Hour}, c, []v1.Pod{starting}, nil)
+
+	var lock sync.Mutex
+	started := []string{}
+	stateV3.AddPodStartedHandler(func(pod *v1.Pod, nodeName string) {
+		lock.Lock()
+		defer lock.Unlock()
+		started = append(started, pod.Name+"/"+nodeName)
+	})
+	assert.Eventually(t, func() bool {
+		stateV3.lock.RLock()
+		defer stateV3.lock.RUnlock()
+		return len(stateV3.startingTimers) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	c.Add(29 * time.Minute)
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notReadyPods)
+
+	c.Add(2 * time.Minute)
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(started) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"starting/node-1"}, started)
+	notReadyPods, err = stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, notReadyPods)
+}
+
+func mockNotReadyCondition(lastTransition time.Time) v1.PodCondition {
+	return v1.PodCondition{
+		Type:               v1.PodReady,
+		Status:             v1.ConditionFalse,
+		LastTransitionTime: meta_v1.Time{Time: lastTransition},
+	}
+}
+
+func mockRestartedContainer(finishedAt time.Time) v1.ContainerStatus {
+	return v1.ContainerStatus{
+		RestartCount: 1,
+		LastTerminationState: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{FinishedAt: meta_v1.Time{Time: finishedAt}},
+		},
+	}
+}
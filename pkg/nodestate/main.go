@@ -2,10 +2,112 @@ package nodestate
 
 import (
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"strconv"
+	"time"
 )
 
+const (
+	// StartupCostAnnotation overrides the default startup cost of a pod
+	StartupCostAnnotation = "thundering-herd/startup-cost"
+	// StartupCPUAnnotation overrides the cpu a pod is expected to consume during startup, e.g. "2500m"
+	StartupCPUAnnotation = "thundering-herd/startup-cpu"
+	// ParallelStartingPodsAnnotation overrides the parallel starting pods of a node
+	ParallelStartingPodsAnnotation = "thundering-herd/parallel-starting-pods"
+	// ParallelStartingPodsPerCoreAnnotation overrides the parallel starting pods per core of a node
+	ParallelStartingPodsPerCoreAnnotation = "thundering-herd/parallel-starting-pods-per-core"
+	// UnlimitedStartingPods is returned if neither the node annotations nor the arguments limit the parallel starting pods
+	UnlimitedStartingPods = -1
+)
+
+// Options configures how the starting pods of a node are weighted
+type Options struct {
+	// DefaultStartupCost is used for pods without startup cost annotation
+	DefaultStartupCost float64
+	// StartedSignal defines when a pod is not starting anymore
+	StartedSignal StartedSignal
+	// MaxStartingDuration excludes pods which are starting for a longer time, 0 disables the exclusion
+	MaxStartingDuration time.Duration
+	// ExcludeBackOff excludes pods with a container in back-off, e.g. CrashLoopBackOff
+	ExcludeBackOff bool
+	// RestartingStartupCost is used instead of the startup cost for pods with a container restart within the RestartingWindow
+	RestartingStartupCost *float64
+	RestartingWindow      time.Duration
+}
+
+// PodStartedHandler is called as soon as a starting pod on a node became ready or was removed
+type PodStartedHandler func(pod *v1.Pod, nodeName string)
+
+// PodDeletedHandler is called for every deleted pod, scheduled or not
+type PodDeletedHandler func(pod *v1.Pod)
+
 type NodeStateInterface interface {
-	NotReadyPods(nodeName string) int
+	Node(nodeName string) (*v1.Node, error)
+	NotReadyPods(nodeName string) (int, error)
+	NotReadyPodsCost(nodeName string) (float64, error)
+	StartupCost(pod *v1.Pod) float64
 	AddSchedulingPod(pod *v1.Pod, nodeName string)
+	RemoveSchedulingPod(pod *v1.Pod, nodeName string)
+	BoundSchedulingPod(pod *v1.Pod, nodeName string)
 	NotReadyPodsAllowedInParallel(*int, *float64, string) (int, error)
+	NotReadyPodsMilliCPU(nodeName string) (int64, error)
+	StartupMilliCPU(pod *v1.Pod) int64
+	MilliCPUAllowedInParallel(startupCPUFraction float64, nodeName string) (int64, error)
+	AddPodStartedHandler(handler PodStartedHandler)
+	AddPodDeletedHandler(handler PodDeletedHandler)
+	NotReadyOwnerPods(ownerKey string, nodeName string) (int, error)
+	NotReadyOwnerPodsClusterWide(ownerKey string) (int, error)
+	NotReadyPodsClusterWide(schedulerName string) (int, error)
+	NotReadyNamespacePods(namespace string) (int, error)
+	IsPodStarting(pod *v1.Pod) bool
+	StartingPods(nodeName string) ([]*v1.Pod, []*v1.Pod, error)
+	NodeNames() ([]string, error)
+}
+
+// OwnerKey identifies the controller owner of a pod, e.g. its ReplicaSet, pods without controller have an empty key
+func OwnerKey(pod *v1.Pod) string {
+	owner := meta_v1.GetControllerOf(pod)
+	if owner == nil {
+		return ""
+	}
+	return pod.Namespace + "/" + owner.Kind + "/" + owner.Name
+}
+
+func podStartupCost(pod *v1.Pod, defaultCost float64) float64 {
+	strVal, exists := pod.Annotations[StartupCostAnnotation]
+	if !exists {
+		return defaultCost
+	}
+
+	val, err := strconv.ParseFloat(strVal, 64)
+	if err != nil || val < 0 {
+		klog.ErrorS(err, "Failed to parse annotation", "annotation", StartupCostAnnotation, "value", strVal, "pod", klog.KObj(pod))
+		return defaultCost
+	}
+
+	return val
+}
+
+// the cpu consumed during startup is taken from the annotation or the cpu limits of the containers,
+// containers without cpu limit are accounted with their cpu request
+func podStartupMilliCPU(pod *v1.Pod) int64 {
+	if strVal, exists := pod.Annotations[StartupCPUAnnotation]; exists {
+		val, err := resource.ParseQuantity(strVal)
+		if err == nil && val.Sign() >= 0 {
+			return val.MilliValue()
+		}
+		klog.ErrorS(err, "Failed to parse annotation", "annotation", StartupCPUAnnotation, "value", strVal, "pod", klog.KObj(pod))
+	}
+
+	var milliCPU int64
+	for _, c := range pod.Spec.Containers {
+		if limit, ok := c.Resources.Limits[v1.ResourceCPU]; ok {
+			milliCPU += limit.MilliValue()
+		} else if request, ok := c.Resources.Requests[v1.ResourceCPU]; ok {
+			milliCPU += request.MilliValue()
+		}
+	}
+	return milliCPU
 }
@@ -0,0 +1,106 @@
+package nodestate
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+)
+
+type StartedSignalType string
+
+const (
+	// StartedSignalPodReady treats a pod as started as soon as the PodReady condition is true
+	StartedSignalPodReady StartedSignalType = "PodReady"
+	// StartedSignalContainersReady treats a pod as started as soon as the ContainersReady condition is true
+	StartedSignalContainersReady StartedSignalType = "ContainersReady"
+	// StartedSignalContainersStarted treats a pod as started as soon as all containers passed their startup probes
+	StartedSignalContainersStarted StartedSignalType = "ContainersStarted"
+	// StartedSignalPodCondition treats a pod as started as soon as the custom condition type is true
+	StartedSignalPodCondition StartedSignalType = "PodCondition"
+	// StartedSignalAnnotation treats a pod as started as soon as the annotation is set to "true"
+	StartedSignalAnnotation StartedSignalType = "Annotation"
+)
+
+// StartedSignal defines when a pod is considered as started and doesn't count as starting pod anymore
+type StartedSignal struct {
+	Type          StartedSignalType
+	ConditionType string
+	Annotation    string
+}
+
+func (s StartedSignal) Validate() error {
+	switch s.Type {
+	case "", StartedSignalPodReady, StartedSignalContainersReady, StartedSignalContainersStarted:
+		return nil
+	case StartedSignalPodCondition:
+		if s.ConditionType == "" {
+			return fmt.Errorf("started signal %s requires a condition type", s.Type)
+		}
+		return nil
+	case StartedSignalAnnotation:
+		if s.Annotation == "" {
+			return fmt.Errorf("started signal %s requires an annotation", s.Type)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown started signal %s", s.Type)
+	}
+}
+
+func (s StartedSignal) isPodStarted(pod *v1.Pod) bool {
+	switch s.Type {
+	case StartedSignalContainersReady:
+		return isConditionTrue(pod, v1.ContainersReady)
+	case StartedSignalContainersStarted:
+		return areContainersStarted(pod)
+	case StartedSignalPodCondition:
+		return isConditionTrue(pod, v1.PodConditionType(s.ConditionType))
+	case StartedSignalAnnotation:
+		return pod.Annotations[s.Annotation] == "true"
+	default:
+		return isPodReady(*pod)
+	}
+}
+
+// conditionType returns the pod condition the started signal is based on
+func (s StartedSignal) conditionType() v1.PodConditionType {
+	switch s.Type {
+	case StartedSignalContainersReady:
+		return v1.ContainersReady
+	case StartedSignalPodCondition:
+		return v1.PodConditionType(s.ConditionType)
+	default:
+		return v1.PodReady
+	}
+}
+
+func isConditionTrue(pod *v1.Pod, conditionType v1.PodConditionType) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == conditionType && c.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// all containers need to report a status, as statuses of containers which are not created yet are missing
+func areContainersStarted(pod *v1.Pod) bool {
+	if len(pod.Status.ContainerStatuses) == 0 || len(pod.Status.ContainerStatuses) < len(pod.Spec.Containers) {
+		return false
+	}
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.Started == nil || !*c.Started {
+			return false
+		}
+	}
+	return true
+}
+
+// copied from https://github.com/helm/helm/blob/d7b4c38c42cb0b77f1bcebf9bb4ae7695a10da0b/pkg/kube/ready.go#L215
+func isPodReady(pod v1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady && c.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package nodestate
+
+import (
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	"testing"
+)
+
+func TestIsPodStarted(t *testing.T) {
+	testcases := []struct {
+		name       string
+		signal     StartedSignal
+		conditions []v1.PodConditionType
+		started    []*bool
+		annotation map[string]string
+		expected   bool
+	}{
+		{
+			name:       "default is pod ready",
+			signal:     StartedSignal{},
+			conditions: []v1.PodConditionType{v1.PodReady},
+			expected:   true,
+		},
+		{
+			name:       "pod ready missing",
+			signal:     StartedSignal{Type: StartedSignalPodReady},
+			conditions: []v1.PodConditionType{v1.ContainersReady},
+			expected:   false,
+		},
+		{
+			name:       "containers ready",
+			signal:     StartedSignal{Type: StartedSignalContainersReady},
+			conditions: []v1.PodConditionType{v1.ContainersReady},
+			expected:   true,
+		},
+		{
+			name:     "all containers started",
+			signal:   StartedSignal{Type: StartedSignalContainersStarted},
+			started:  []*bool{ptr.To(true), ptr.To(true)},
+			expected: true,
+		},
+		{
+			name:     "container not started",
+			signal:   StartedSignal{Type: StartedSignalContainersStarted},
+			started:  []*bool{ptr.To(true), ptr.To(false)},
+			expected: false,
+		},
+		{
+			name:     "container without started status",
+			signal:   StartedSignal{Type: StartedSignalContainersStarted},
+			started:  []*bool{ptr.To(true), nil},
+			expected: false,
+		},
+		{
+			name:     "no container status",
+			signal:   StartedSignal{Type: StartedSignalContainersStarted},
+			started:  []*bool{},
+			expected: false,
+		},
+		{
+			name:       "custom condition",
+			signal:     StartedSignal{Type: StartedSignalPodCondition, ConditionType: "example.com/Warm"},
+			conditions: []v1.PodConditionType{v1.PodReady, "example.com/Warm"},
+			expected:   true,
+		},
+		{
+			name:       "custom condition missing",
+			signal:     StartedSignal{Type: StartedSignalPodCondition, ConditionType: "example.com/Warm"},
+			conditions: []v1.PodConditionType{v1.PodReady},
+			expected:   false,
+		},
+		{
+			name:       "annotation",
+			signal:     StartedSignal{Type: StartedSignalAnnotation, Annotation: "example.com/started"},
+			annotation: map[string]string{"example.com/started": "true"},
+			expected:   true,
+		},
+		{
+			name:       "annotation not true",
+			signal:     StartedSignal{Type: StartedSignalAnnotation, Annotation: "example.com/started"},
+			annotation: map[string]string{"example.com/started": "false"},
+			expected:   false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+			pod.Annotations = tc.annotation
+			for _, c := range tc.conditions {
+				pod.Status.Conditions = append(pod.Status.Conditions, v1.PodCondition{Type: c, Status: v1.ConditionTrue})
+			}
+			for _, s := range tc.started {
+				pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{})
+				pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{Started: s})
+			}
+
+			assert.Equal(t, tc.expected, tc.signal.isPodStarted(&pod))
+		})
+	}
+}
+
+func TestStartedSignalValidate(t *testing.T) {
+	testcases := []struct {
+		name        string
+		signal      StartedSignal
+		errExpected bool
+	}{
+		{
+			name:   "empty",
+			signal: StartedSignal{},
+		},
+		{
+			name:   "containers started",
+			signal: StartedSignal{Type: StartedSignalContainersStarted},
+		},
+		{
+			name:        "condition without type",
+			signal:      StartedSignal{Type: StartedSignalPodCondition},
+			errExpected: true,
+		},
+		{
+			name:        "annotation without name",
+			signal:      StartedSignal{Type: StartedSignalAnnotation},
+			errExpected: true,
+		},
+		{
+			name:        "unknown",
+			signal:      StartedSignal{Type: "Warm"},
+			errExpected: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.signal.Validate()
+			if tc.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
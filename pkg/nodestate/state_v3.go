@@ -0,0 +1,621 @@
+package nodestate
+
+import (
+	"fmt"
+	"github.com/benbjohnson/clock"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/metrics"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"math"
+	"sort"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sync"
+)
+
+const (
+	// NodeNameIndex indexes the pods of the shared informer by the node they are assigned to
+	NodeNameIndex = "thunderingherd.nodeName"
+	// OwnerIndex indexes the pods of the shared informer by their controller owner
+	OwnerIndex = "thunderingherd.owner"
+	// SchedulerNameIndex indexes the pods of the shared informer by the scheduler responsible for them
+	SchedulerNameIndex = "thunderingherd.schedulerName"
+)
+
+// NodeStateV3 keeps track of not ready pods based on the shared informers of the scheduler
+// instead of querying the api server on every permit call
+type NodeStateV3 struct {
+	// pods which are permitted, but not yet observed on the node by the informer
+	scheduledPods map[string]map[string]*v1.Pod
+	// timers notifying the handlers once a starting pod exceeded the max starting duration
+	startingTimers map[string]*clock.Timer
+	handlers       []PodStartedHandler
+	deleteHandlers []PodDeletedHandler
+	options        Options
+	podIndexer     cache.Indexer
+	nodeLister     corelisters.NodeLister
+	lock           *sync.RWMutex
+	clock          clock.Clock
+}
+
+func NewNodeStateV3(informerFactory informers.SharedInformerFactory, options Options) (NodeStateInterface, error) {
+	return internalNewNodeStateV3(informerFactory, options, clock.New())
+}
+
+func internalNewNodeStateV3(informerFactory informers.SharedInformerFactory, options Options, c clock.Clock) (*NodeStateV3, error) {
+	podInformer := informerFactory.Core().V1().Pods().Informer()
+	// multiple scheduler profiles share the same informer, therefore the indexes are only added once
+	indexers := cache.Indexers{}
+	for name, indexFunc := range map[string]cache.IndexFunc{
+		NodeNameIndex:        podNodeNameIndexFunc,
+		OwnerIndex:           podOwnerIndexFunc,
+		SchedulerNameIndex:   podSchedulerNameIndexFunc,
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	} {
+		if _, exists := podInformer.GetIndexer().GetIndexers()[name]; !exists {
+			indexers[name] = indexFunc
+		}
+	}
+	if len(indexers) > 0 {
+		if err := podInformer.AddIndexers(indexers); err != nil {
+			return nil, fmt.Errorf("failed to add indexes to pod informer: %v", err)
+		}
+	}
+
+	var lock = sync.RWMutex{}
+	n := &NodeStateV3{
+		scheduledPods:  make(map[string]map[string]*v1.Pod),
+		startingTimers: make(map[string]*clock.Timer),
+		options:        options,
+		podIndexer:     podInformer.GetIndexer(),
+		nodeLister:     informerFactory.Core().V1().Nodes().Lister(),
+		lock:           &lock,
+		clock:          c,
+	}
+
+	_, err := podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    n.onPodAdd,
+		UpdateFunc: n.onPodUpdate,
+		DeleteFunc: n.onPodDelete,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add event handler to pod informer: %v", err)
+	}
+	_, err = informerFactory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: n.onNodeDelete,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add event handler to node informer: %v", err)
+	}
+
+	return n, nil
+}
+
+func (n *NodeStateV3) Node(nodeName string) (*v1.Node, error) {
+	node, err := n.nodeLister.Get(nodeName)
+	if err != nil {
+		return nil, lookupError("node %s can't be found in informer cache: %v", nodeName, err)
+	}
+	return node, nil
+}
+
+// NotReadyPodsAllowedInParallel prefers the annotations of the node over the given arguments,
+// UnlimitedStartingPods is returned if none of them is set
+func (n *NodeStateV3) NotReadyPodsAllowedInParallel(parallelStartingPodsPerNode *int, parallelStartingPodsPerCore *float64, nodeName string) (int, error) {
+	node, err := n.nodeLister.Get(nodeName)
+	if err != nil {
+		if parallelStartingPodsPerNode != nil {
+			return *parallelStartingPodsPerNode, nil
+		}
+		return -1, lookupError("node %s can't be found in informer cache: %v", nodeName, err)
+	}
+
+	if val, ok := nodeAnnotationInt(node, ParallelStartingPodsAnnotation); ok {
+		return val, nil
+	}
+
+	allocatableCpu := node.Status.Allocatable.Cpu()
+	if val, ok := nodeAnnotationFloat(node, ParallelStartingPodsPerCoreAnnotation); ok {
+		return calculateParallelStartingPodsPerCore(val, allocatableCpu), nil
+	}
+
+	if parallelStartingPodsPerNode != nil {
+		return *parallelStartingPodsPerNode, nil
+	}
+	if parallelStartingPodsPerCore == nil {
+		return UnlimitedStartingPods, nil
+	}
+
+	return calculateParallelStartingPodsPerCore(*parallelStartingPodsPerCore, allocatableCpu), nil
+}
+
+func (n *NodeStateV3) MilliCPUAllowedInParallel(startupCPUFraction float64, nodeName string) (int64, error) {
+	node, err := n.nodeLister.Get(nodeName)
+	if err != nil {
+		return -1, lookupError("node %s can't be found in informer cache: %v", nodeName, err)
+	}
+
+	return calculateStartingMilliCPU(startupCPUFraction, node.Status.Allocatable.Cpu()), nil
+}
+
+func (n *NodeStateV3) NotReadyPods(nodeName string) (int, error) {
+	notReadyPods := 0
+	err := n.forEachStartingPod(nodeName, func(_ *v1.Pod) {
+		notReadyPods++
+	})
+	if err != nil {
+		return -1, lookupError("failed to lookup pods on node %s: %v", nodeName, err)
+	}
+
+	return notReadyPods, nil
+}
+
+func (n *NodeStateV3) NotReadyPodsCost(nodeName string) (float64, error) {
+	cost := 0.0
+	err := n.forEachStartingPod(nodeName, func(pod *v1.Pod) {
+		cost += n.StartupCost(pod)
+	})
+	if err != nil {
+		return -1, lookupError("failed to lookup pods on node %s: %v", nodeName, err)
+	}
+
+	return cost, nil
+}
+
+func (n *NodeStateV3) StartupCost(pod *v1.Pod) float64 {
+	if n.options.RestartingStartupCost != nil && n.isPodRestarting(pod) {
+		return *n.options.RestartingStartupCost
+	}
+	return podStartupCost(pod, n.options.DefaultStartupCost)
+}
+
+func (n *NodeStateV3) NotReadyPodsMilliCPU(nodeName string) (int64, error) {
+	var milliCPU int64
+	err := n.forEachStartingPod(nodeName, func(pod *v1.Pod) {
+		milliCPU += n.StartupMilliCPU(pod)
+	})
+	if err != nil {
+		return -1, lookupError("failed to lookup pods on node %s: %v", nodeName, err)
+	}
+
+	return milliCPU, nil
+}
+
+func (n *NodeStateV3) StartupMilliCPU(pod *v1.Pod) int64 {
+	return podStartupMilliCPU(pod)
+}
+
+// NotReadyOwnerPods counts the not ready pods of the owner on the node
+func (n *NodeStateV3) NotReadyOwnerPods(ownerKey string, nodeName string) (int, error) {
+	notReadyPods := 0
+	err := n.forEachStartingOwnerPod(ownerKey, func(pod *v1.Pod, podNodeName string) {
+		if podNodeName == nodeName {
+			notReadyPods++
+		}
+	})
+	if err != nil {
+		return -1, lookupError("failed to lookup pods of owner %s: %v", ownerKey, err)
+	}
+
+	return notReadyPods, nil
+}
+
+// NotReadyOwnerPodsClusterWide counts the not ready pods of the owner on all nodes
+func (n *NodeStateV3) NotReadyOwnerPodsClusterWide(ownerKey string) (int, error) {
+	notReadyPods := 0
+	err := n.forEachStartingOwnerPod(ownerKey, func(_ *v1.Pod, _ string) {
+		notReadyPods++
+	})
+	if err != nil {
+		return -1, lookupError("failed to lookup pods of owner %s: %v", ownerKey, err)
+	}
+
+	return notReadyPods, nil
+}
+
+// NotReadyNamespacePods counts the not ready pods of the namespace on all nodes
+func (n *NodeStateV3) NotReadyNamespacePods(namespace string) (int, error) {
+	objs, err := n.podIndexer.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return -1, lookupError("failed to lookup pods of namespace %s: %v", namespace, err)
+	}
+
+	notReadyPods := 0
+	n.forEachStarting(objs, func(_ string, pod *v1.Pod) bool {
+		return pod.Namespace == namespace
+	}, func(_ *v1.Pod, _ string) {
+		notReadyPods++
+	})
+	return notReadyPods, nil
+}
+
+// NotReadyPodsClusterWide counts the not ready pods of the scheduler on all nodes, an empty scheduler name counts the pods of all schedulers
+func (n *NodeStateV3) NotReadyPodsClusterWide(schedulerName string) (int, error) {
+	var objs []interface{}
+	if schedulerName == "" {
+		objs = n.podIndexer.List()
+	} else {
+		var err error
+		objs, err = n.podIndexer.ByIndex(SchedulerNameIndex, schedulerName)
+		if err != nil {
+			return -1, lookupError("failed to lookup pods of scheduler %s: %v", schedulerName, err)
+		}
+	}
+
+	notReadyPods := 0
+	n.forEachStarting(objs, func(_ string, pod *v1.Pod) bool {
+		return schedulerName == "" || pod.Spec.SchedulerName == schedulerName
+	}, func(_ *v1.Pod, _ string) {
+		notReadyPods++
+	})
+	return notReadyPods, nil
+}
+
+// forEachStartingPod calls fn for every not ready pod on the node and for every reserved pod which was not yet observed
+func (n *NodeStateV3) forEachStartingPod(nodeName string, fn func(pod *v1.Pod)) error {
+	objs, err := n.podIndexer.ByIndex(NodeNameIndex, nodeName)
+	if err != nil {
+		return err
+	}
+
+	n.forEachStarting(objs, func(reservedNodeName string, _ *v1.Pod) bool {
+		return reservedNodeName == nodeName
+	}, func(pod *v1.Pod, _ string) {
+		fn(pod)
+	})
+	return nil
+}
+
+// forEachStartingOwnerPod calls fn for every not ready pod of the owner and for every reserved pod of the owner which was not yet observed
+func (n *NodeStateV3) forEachStartingOwnerPod(ownerKey string, fn func(pod *v1.Pod, nodeName string)) error {
+	objs, err := n.podIndexer.ByIndex(OwnerIndex, ownerKey)
+	if err != nil {
+		return err
+	}
+
+	n.forEachStarting(objs, func(_ string, pod *v1.Pod) bool {
+		return OwnerKey(pod) == ownerKey
+	}, fn)
+	return nil
+}
+
+func (n *NodeStateV3) forEachStarting(objs []interface{}, reserved func(nodeName string, pod *v1.Pod) bool, fn func(pod *v1.Pod, nodeName string)) {
+	notReadyPods, observedPods := n.notReadyPods(objs)
+	for _, pod := range notReadyPods {
+		fn(pod, pod.Spec.NodeName)
+	}
+
+	for nodeName, pods := range n.scheduledPodsMatching(reserved, observedPods) {
+		for _, pod := range pods {
+			fn(pod, nodeName)
+		}
+	}
+}
+
+// notReadyPods returns the starting pods assigned to a node and the keys of all observed pods
+func (n *NodeStateV3) notReadyPods(objs []interface{}) ([]*v1.Pod, map[string]bool) {
+	// a reservation is released asynchronously after its pod was observed, so it must not be counted twice
+	observedPods := make(map[string]bool, len(objs))
+	var notReadyPods []*v1.Pod
+	for _, obj := range objs {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || pod.Spec.NodeName == "" {
+			continue
+		}
+		observedPods[podStoringKey(pod)] = true
+		if n.IsPodStarting(pod) {
+			notReadyPods = append(notReadyPods, pod)
+		}
+	}
+	return notReadyPods, observedPods
+}
+
+// StartingPods returns the not ready pods on the node and the pods with a reservation which were not yet observed on it
+func (n *NodeStateV3) StartingPods(nodeName string) ([]*v1.Pod, []*v1.Pod, error) {
+	objs, err := n.podIndexer.ByIndex(NodeNameIndex, nodeName)
+	if err != nil {
+		return nil, nil, lookupError("failed to lookup pods on node %s: %v", nodeName, err)
+	}
+
+	notReadyPods, observedPods := n.notReadyPods(objs)
+	scheduledPods := n.scheduledPodsMatching(func(reservedNodeName string, _ *v1.Pod) bool {
+		return reservedNodeName == nodeName
+	}, observedPods)
+	return notReadyPods, scheduledPods[nodeName], nil
+}
+
+// NodeNames returns the names of all nodes known to the informer
+func (n *NodeStateV3) NodeNames() ([]string, error) {
+	nodes, err := n.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, lookupError("failed to list nodes: %v", err)
+	}
+
+	nodeNames := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	sort.Strings(nodeNames)
+	return nodeNames, nil
+}
+
+// AddSchedulingPod reserves a starting slot on the node until the pod is observed on it or the reservation is removed
+func (n *NodeStateV3) AddSchedulingPod(pod *v1.Pod, nodeName string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if _, ok := n.scheduledPods[nodeName]; !ok {
+		n.scheduledPods[nodeName] = make(map[string]*v1.Pod)
+	}
+	n.scheduledPods[nodeName][podStoringKey(pod)] = pod
+}
+
+func (n *NodeStateV3) RemoveSchedulingPod(pod *v1.Pod, nodeName string) {
+	if n.removeReservation(pod, nodeName) {
+		n.notifyPodStarted(pod, nodeName)
+	}
+}
+
+// BoundSchedulingPod releases the reservation in case the informer already observed the bound pod,
+// otherwise the reservation is kept until the pod shows up on the node
+func (n *NodeStateV3) BoundSchedulingPod(pod *v1.Pod, nodeName string) {
+	obj, exists, err := n.podIndexer.Get(pod)
+	if err != nil || !exists {
+		return
+	}
+
+	if observed, ok := obj.(*v1.Pod); ok && observed.UID == pod.UID && observed.Spec.NodeName == nodeName {
+		n.onPodObserved(observed)
+	}
+}
+
+func (n *NodeStateV3) AddPodStartedHandler(handler PodStartedHandler) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.handlers = append(n.handlers, handler)
+}
+
+func (n *NodeStateV3) AddPodDeletedHandler(handler PodDeletedHandler) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.deleteHandlers = append(n.deleteHandlers, handler)
+}
+
+func (n *NodeStateV3) onPodAdd(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+
+	n.onPodObserved(pod)
+}
+
+func (n *NodeStateV3) onPodUpdate(oldObj, newObj interface{}) {
+	oldPod, ok := oldObj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	newPod, ok := newObj.(*v1.Pod)
+	if !ok {
+		return
+	}
+
+	if n.IsPodStarting(oldPod) && !n.IsPodStarting(newPod) {
+		n.stopWatchingStartingDuration(newPod)
+		n.notifyPodStarted(newPod, oldPod.Spec.NodeName)
+	}
+	n.onPodObserved(newPod)
+}
+
+func (n *NodeStateV3) onPodDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+
+	nodeName := pod.Spec.NodeName
+	if nodeName == "" {
+		nodeName = n.reservedNode(pod)
+	}
+
+	n.stopWatchingStartingDuration(pod)
+	removed := n.removeReservation(pod, nodeName)
+	if removed || n.IsPodStarting(pod) {
+		n.notifyPodStarted(pod, nodeName)
+	}
+	n.notifyPodDeleted(pod)
+}
+
+func (n *NodeStateV3) onNodeDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return
+	}
+
+	metrics.DeleteNode(node.Name)
+}
+
+// as soon as a pod is visible on its node, it's counted by the informer and the reservation is not needed anymore
+func (n *NodeStateV3) onPodObserved(pod *v1.Pod) {
+	if pod.Spec.NodeName == "" {
+		return
+	}
+	n.watchStartingDuration(pod)
+
+	if n.removeReservation(pod, pod.Spec.NodeName) && !n.IsPodStarting(pod) {
+		n.notifyPodStarted(pod, pod.Spec.NodeName)
+	}
+}
+
+func (n *NodeStateV3) removeReservation(pod *v1.Pod, nodeName string) bool {
+	podKey := podStoringKey(pod)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if _, ok := n.scheduledPods[nodeName][podKey]; !ok {
+		return false
+	}
+
+	delete(n.scheduledPods[nodeName], podKey)
+	if len(n.scheduledPods[nodeName]) == 0 {
+		delete(n.scheduledPods, nodeName)
+	}
+	return true
+}
+
+func (n *NodeStateV3) reservedNode(pod *v1.Pod) string {
+	podKey := podStoringKey(pod)
+
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	for nodeName, pods := range n.scheduledPods {
+		if _, ok := pods[podKey]; ok {
+			return nodeName
+		}
+	}
+	return ""
+}
+
+// handlers are called without holding the lock, as they usually query the node state again
+func (n *NodeStateV3) notifyPodStarted(pod *v1.Pod, nodeName string) {
+	n.lock.RLock()
+	handlers := make([]PodStartedHandler, len(n.handlers))
+	copy(handlers, n.handlers)
+	n.lock.RUnlock()
+
+	for _, handler := range handlers {
+		handler(pod, nodeName)
+	}
+}
+
+func (n *NodeStateV3) notifyPodDeleted(pod *v1.Pod) {
+	n.lock.RLock()
+	handlers := make([]PodDeletedHandler, len(n.deleteHandlers))
+	copy(handlers, n.deleteHandlers)
+	n.lock.RUnlock()
+
+	for _, handler := range handlers {
+		handler(pod)
+	}
+}
+
+// scheduledPodsMatching returns the reserved pods per node which match and were not yet observed
+func (n *NodeStateV3) scheduledPodsMatching(match func(nodeName string, pod *v1.Pod) bool, observedPods map[string]bool) map[string][]*v1.Pod {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	scheduledPods := make(map[string][]*v1.Pod)
+	for nodeName, pods := range n.scheduledPods {
+		for podKey, pod := range pods {
+			if !observedPods[podKey] && match(nodeName, pod) {
+				scheduledPods[nodeName] = append(scheduledPods[nodeName], pod)
+			}
+		}
+	}
+
+	return scheduledPods
+}
+
+// lookupError counts a failed lookup of the informer cache as dependency error
+func lookupError(format string, a ...interface{}) error {
+	metrics.DependencyErrors.WithLabelValues("nodestate").Inc()
+	return fmt.Errorf(format, a...)
+}
+
+func nodeAnnotationInt(node *v1.Node, annotation string) (int, bool) {
+	strVal, exists := node.Annotations[annotation]
+	if !exists {
+		return 0, false
+	}
+
+	val, err := strconv.Atoi(strVal)
+	if err != nil || val < 0 {
+		klog.ErrorS(err, "Failed to parse annotation", "annotation", annotation, "value", strVal, "node", klog.KObj(node))
+		return 0, false
+	}
+	return val, true
+}
+
+func nodeAnnotationFloat(node *v1.Node, annotation string) (float64, bool) {
+	strVal, exists := node.Annotations[annotation]
+	if !exists {
+		return 0, false
+	}
+
+	val, err := strconv.ParseFloat(strVal, 64)
+	if err != nil || val < 0 {
+		klog.ErrorS(err, "Failed to parse annotation", "annotation", annotation, "value", strVal, "node", klog.KObj(node))
+		return 0, false
+	}
+	return val, true
+}
+
+func podNodeNameIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return []string{}, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+func podOwnerIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return []string{}, nil
+	}
+	ownerKey := OwnerKey(pod)
+	if ownerKey == "" {
+		return []string{}, nil
+	}
+	return []string{ownerKey}, nil
+}
+
+func podSchedulerNameIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return []string{}, nil
+	}
+	return []string{pod.Spec.SchedulerName}, nil
+}
+
+func podStoringKey(pod *v1.Pod) string {
+	return fmt.Sprintf("%s-%s-%s", pod.Name, pod.Namespace, pod.UID)
+}
+
+// IsPodStarting reports if a pod is assigned to a node, but neither started, terminated nor excluded
+func (n *NodeStateV3) IsPodStarting(pod *v1.Pod) bool {
+	return pod.Spec.NodeName != "" && !isPodTerminated(pod) && !n.options.StartedSignal.isPodStarted(pod) && !n.isPodExcluded(pod)
+}
+
+// the scheduler informer already filters terminated pods, but other informer factories don't
+func isPodTerminated(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+// regardless of number of cores in order to avoid starvation, at least one node can be scheduled
+func calculateParallelStartingPodsPerCore(podsPerCore float64, cpu *resource.Quantity) int {
+	val := cpu.AsApproximateFloat64() * podsPerCore
+	if val < 1 {
+		return 1
+	}
+	return int(math.Floor(val))
+}
+
+func calculateStartingMilliCPU(startupCPUFraction float64, cpu *resource.Quantity) int64 {
+	return int64(math.Floor(float64(cpu.MilliValue()) * startupCPUFraction))
+}
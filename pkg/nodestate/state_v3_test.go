@@ -0,0 +1,1017 @@
+package nodestate
+
+import (
+	"context"
+	"github.com/benbjohnson/clock"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/utils/ptr"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testOptions = Options{
+	DefaultStartupCost: 1,
+}
+
+func TestShouldCountNotReadyPodsWithZero(t *testing.T) {
+	pods := []v1.Pod{
+		mockRunningPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"),
+		mockRunningPod("test-pod-2", "ns-2", "d14b61cd-4a3a-477e-ac0a-2b2c50f301ee", "node-1"),
+		mockRunningPod("test-pod-3", "ns-3", "36847994-2dae-46e3-8ee5-af6afc2a5d63", "node-1"),
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 0 {
+		t.Errorf("Expected 0 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldCountNotReadyPodsAndFindSome(t *testing.T) {
+	pods := []v1.Pod{
+		mockRunningPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"),
+		mockUnhealthyPod("test-pod-2", "ns-2", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-1"),
+		mockUnhealthyPod("test-pod-3", "ns-3", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1"),
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 2 {
+		t.Errorf("Expected 2 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldIgnoreTerminatedPodsAndPodsOnOtherNodes(t *testing.T) {
+	succeeded := mockUnhealthyPod("test-pod-3", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1")
+	succeeded.Status.Phase = v1.PodSucceeded
+
+	pods := []v1.Pod{
+		mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"),
+		mockUnhealthyPod("test-pod-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-2"),
+		mockUnhealthyPod("test-pod-4", "ns-1", "9a2a4b63-35b4-4e0c-a6cb-c9ce0a3c1b0e", ""),
+		succeeded,
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 1 {
+		t.Errorf("Expected 1 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldShareNodeNameIndexBetweenInstances(t *testing.T) {
+	informerFactory := informers.NewSharedInformerFactory(testclient.NewSimpleClientset(), 0)
+
+	_, err := NewNodeStateV3(informerFactory, testOptions)
+	assert.NoError(t, err)
+	_, err = NewNodeStateV3(informerFactory, testOptions)
+	assert.NoError(t, err)
+}
+
+func TestShouldNotifyHandlersWhenStartingPodBecameReadyOrWasDeleted(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	stateV3, err := NewNodeStateV3(informerFactory, testOptions)
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	started := []string{}
+	stateV3.AddPodStartedHandler(func(pod *v1.Pod, nodeName string) {
+		lock.Lock()
+		defer lock.Unlock()
+		started = append(started, pod.Name+"/"+nodeName)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	pod1 := mockUnhealthyPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	pod2 := mockUnhealthyPod("pod-2", "ns-1", "bb0acc1a-46a0-446b-86e4-30dfae9ad450", "node-2")
+	for _, pod := range []v1.Pod{pod1, pod2} {
+		_, err = client.CoreV1().Pods(pod.Namespace).Create(context.TODO(), &pod, meta_v1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	ready := mockRunningPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	_, err = client.CoreV1().Pods(ready.Namespace).Update(context.TODO(), &ready, meta_v1.UpdateOptions{})
+	assert.NoError(t, err)
+	err = client.CoreV1().Pods(pod2.Namespace).Delete(context.TODO(), pod2.Name, meta_v1.DeleteOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(started) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"pod-1/node-1", "pod-2/node-2"}, started)
+}
+
+func TestShouldNotifyHandlersWhenPodWasDeleted(t *testing.T) {
+	pending := mockUnhealthyPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "")
+	running := mockRunningPod("pod-2", "ns-1", "bb0acc1a-46a0-446b-86e4-30dfae9ad450", "node-1")
+	client := testclient.NewSimpleClientset(&pending, &running)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	stateV3, err := NewNodeStateV3(informerFactory, testOptions)
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	deleted := []string{}
+	stateV3.AddPodDeletedHandler(func(pod *v1.Pod) {
+		lock.Lock()
+		defer lock.Unlock()
+		deleted = append(deleted, pod.Name)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	for _, pod := range []v1.Pod{pending, running} {
+		err = client.CoreV1().Pods(pod.Namespace).Delete(context.TODO(), pod.Name, meta_v1.DeleteOptions{})
+		assert.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(deleted) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"pod-1", "pod-2"}, deleted)
+}
+
+func TestShouldSumStartupCostOfNotReadyPods(t *testing.T) {
+	cheap := mockUnhealthyPod("test-pod-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-1")
+	cheap.Annotations = map[string]string{StartupCostAnnotation: "0.25"}
+	expensive := mockUnhealthyPod("test-pod-3", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1")
+	expensive.Annotations = map[string]string{StartupCostAnnotation: "3"}
+
+	pods := []v1.Pod{
+		mockRunningPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"),
+		cheap,
+		expensive,
+		mockUnhealthyPod("test-pod-4", "ns-1", "9a2a4b63-35b4-4e0c-a6cb-c9ce0a3c1b0e", "node-1"),
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+	scheduling := mockUnhealthyPod("test-pod-5", "ns-1", "36847994-2dae-46e3-8ee5-af6afc2a5d63", "")
+	stateV3.AddSchedulingPod(&scheduling, "node-1")
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, notReadyPods)
+	cost, err := stateV3.NotReadyPodsCost("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 5.25, cost)
+}
+
+func TestPodStartupCost(t *testing.T) {
+	testcases := []struct {
+		name        string
+		annotations map[string]string
+		expected    float64
+	}{
+		{
+			name:        "no annotation",
+			annotations: nil,
+			expected:    1.5,
+		},
+		{
+			name:        "annotation",
+			annotations: map[string]string{StartupCostAnnotation: "0.5"},
+			expected:    0.5,
+		},
+		{
+			name:        "zero",
+			annotations: map[string]string{StartupCostAnnotation: "0"},
+			expected:    0,
+		},
+		{
+			name:        "negative",
+			annotations: map[string]string{StartupCostAnnotation: "-2"},
+			expected:    1.5,
+		},
+		{
+			name:        "malformed",
+			annotations: map[string]string{StartupCostAnnotation: "much"},
+			expected:    1.5,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+			pod.Annotations = tc.annotations
+			assert.Equal(t, tc.expected, podStartupCost(&pod, 1.5))
+		})
+	}
+}
+
+func TestShouldSumStartupMilliCPUOfNotReadyPods(t *testing.T) {
+	limited := mockUnhealthyPod("test-pod-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-1")
+	limited.Spec.Containers = []v1.Container{mockContainer(nil, ptr.To("1500m"))}
+	annotated := mockUnhealthyPod("test-pod-3", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1")
+	annotated.Annotations = map[string]string{StartupCPUAnnotation: "2"}
+	ready := mockRunningPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+	ready.Spec.Containers = []v1.Container{mockContainer(nil, ptr.To("4"))}
+
+	stateV3 := newTestNodeState(t, []v1.Pod{ready, limited, annotated}, nil)
+
+	milliCPU, err := stateV3.NotReadyPodsMilliCPU("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3500), milliCPU)
+}
+
+func TestPodStartupMilliCPU(t *testing.T) {
+	testcases := []struct {
+		name        string
+		annotations map[string]string
+		containers  []v1.Container
+		expected    int64
+	}{
+		{
+			name:       "no resources",
+			containers: []v1.Container{mockContainer(nil, nil)},
+			expected:   0,
+		},
+		{
+			name: "limits of all containers",
+			containers: []v1.Container{
+				mockContainer(ptr.To("100m"), ptr.To("2")),
+				mockContainer(nil, ptr.To("500m")),
+			},
+			expected: 2500,
+		},
+		{
+			name: "requests without limits",
+			containers: []v1.Container{
+				mockContainer(ptr.To("100m"), ptr.To("1")),
+				mockContainer(ptr.To("250m"), nil),
+			},
+			expected: 1250,
+		},
+		{
+			name:        "annotation",
+			annotations: map[string]string{StartupCPUAnnotation: "3200m"},
+			containers:  []v1.Container{mockContainer(nil, ptr.To("1"))},
+			expected:    3200,
+		},
+		{
+			name:        "malformed annotation",
+			annotations: map[string]string{StartupCPUAnnotation: "lots"},
+			containers:  []v1.Container{mockContainer(nil, ptr.To("1"))},
+			expected:    1000,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+			pod.Annotations = tc.annotations
+			pod.Spec.Containers = tc.containers
+			assert.Equal(t, tc.expected, podStartupMilliCPU(&pod))
+		})
+	}
+}
+
+func TestMilliCPUAllowedInParallel(t *testing.T) {
+	testcases := []struct {
+		name               string
+		startupCPUFraction float64
+		nodeName           string
+		nodeAllocatableCPU *string
+		errExpected        bool
+		expected           int64
+	}{
+		{
+			name:               "full node",
+			startupCPUFraction: 1,
+			nodeName:           "node-1",
+			nodeAllocatableCPU: ptr.To("4"),
+			expected:           4000,
+		},
+		{
+			name:               "fraction",
+			startupCPUFraction: 0.75,
+			nodeName:           "node-1",
+			nodeAllocatableCPU: ptr.To("1900m"),
+			expected:           1425,
+		},
+		{
+			name:               "no cpu resource",
+			startupCPUFraction: 0.75,
+			nodeName:           "node-1",
+			nodeAllocatableCPU: nil,
+			expected:           0,
+		},
+		{
+			name:               "unknown node",
+			startupCPUFraction: 0.75,
+			nodeName:           "node-2",
+			nodeAllocatableCPU: ptr.To("4"),
+			errExpected:        true,
+			expected:           -1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestNodeState(t, nil, []v1.Node{mockNode("node-1", tc.nodeAllocatableCPU)})
+
+			result, err := n.MilliCPUAllowedInParallel(tc.startupCPUFraction, tc.nodeName)
+			if tc.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestShouldAddPodInSchedulingPhaseToInternalList(t *testing.T) {
+	stateV3 := newTestNodeState(t, nil, nil)
+
+	pod := mockRunningPod("qwe", "asd", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	stateV3.AddSchedulingPod(&pod, "node-1")
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 1 {
+		t.Errorf("Expected 1 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldAddMultiplePodsInSchedulingPhaseToInternalList(t *testing.T) {
+	stateV3 := newTestNodeState(t, nil, nil)
+
+	pod1 := mockRunningPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	pod2 := mockRunningPod("pod-2", "ns-1", "bb0acc1a-46a0-446b-86e4-30dfae9ad450", "node-1")
+	stateV3.AddSchedulingPod(&pod1, "node-1")
+	stateV3.AddSchedulingPod(&pod2, "node-1")
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 2 {
+		t.Errorf("Expected 2 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldNotAppendSchedulingPodMultipleTimes(t *testing.T) {
+	stateV3 := newTestNodeState(t, nil, nil)
+
+	pod1 := mockRunningPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+
+	stateV3.AddSchedulingPod(&pod1, "node-1")
+	stateV3.AddSchedulingPod(&pod1, "node-1")
+	stateV3.AddSchedulingPod(&pod1, "node-1")
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 1 {
+		t.Errorf("Expected 1 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldRemoveFromInSchedulingPodList(t *testing.T) {
+	stateV3 := newTestNodeState(t, nil, nil)
+
+	pod1 := mockRunningPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	pod2 := mockRunningPod("pod-12", "ns-1", "532ee84e-ad8f-4a5b-99e3-b52ef909226b", "node-1")
+	stateV3.AddSchedulingPod(&pod1, "node-1")
+	stateV3.AddSchedulingPod(&pod2, "node-1")
+
+	stateV3.RemoveSchedulingPod(&pod1, "node-1")
+	stateV3.RemoveSchedulingPod(&pod2, "node-1")
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 0 {
+		t.Errorf("Expected 0 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldKeepSchedulingPodUntilObservedOnNode(t *testing.T) {
+	stateV3 := newTestNodeState(t, nil, nil)
+
+	pod := mockUnhealthyPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	stateV3.AddSchedulingPod(&pod, "node-1")
+	stateV3.BoundSchedulingPod(&pod, "node-1")
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notReadyPods)
+
+	ready := mockRunningPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	err = stateV3.podIndexer.Add(&ready)
+	assert.NoError(t, err)
+	notReadyPods, err = stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, notReadyPods)
+
+	stateV3.BoundSchedulingPod(&ready, "node-1")
+	assert.Empty(t, stateV3.scheduledPods)
+}
+
+func TestShouldReleaseSchedulingPodWhenObservedByInformer(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	stateV3, err := internalNewNodeStateV3(informerFactory, testOptions, clock.New())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	pod1 := mockUnhealthyPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	pod2 := mockUnhealthyPod("pod-2", "ns-1", "bb0acc1a-46a0-446b-86e4-30dfae9ad450", "")
+	stateV3.AddSchedulingPod(&pod1, "node-1")
+	stateV3.AddSchedulingPod(&pod2, "node-1")
+
+	_, err = client.CoreV1().Pods(pod1.Namespace).Create(context.TODO(), &pod1, meta_v1.CreateOptions{})
+	assert.NoError(t, err)
+	_, err = client.CoreV1().Pods(pod2.Namespace).Create(context.TODO(), &pod2, meta_v1.CreateOptions{})
+	assert.NoError(t, err)
+	err = client.CoreV1().Pods(pod2.Namespace).Delete(context.TODO(), pod2.Name, meta_v1.DeleteOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		stateV3.lock.RLock()
+		defer stateV3.lock.RUnlock()
+		return len(stateV3.scheduledPods) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notReadyPods)
+}
+
+func TestShouldNotCountObservedSchedulingPodTwice(t *testing.T) {
+	pod := mockUnhealthyPod("pod-1", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", "node-1")
+	stateV3 := newTestNodeState(t, []v1.Pod{pod}, nil)
+
+	stateV3.AddSchedulingPod(&pod, "node-1")
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	if notReadyPods != 1 {
+		t.Errorf("Expected 1 unhealthy pods but got %d", notReadyPods)
+	}
+}
+
+func TestShouldCountNotReadyPodsBasedOnStartedSignal(t *testing.T) {
+	started := mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1")
+	started.Status.ContainerStatuses = []v1.ContainerStatus{{Started: ptr.To(true)}}
+	pods := []v1.Pod{
+		started,
+		mockUnhealthyPod("test-pod-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-1"),
+	}
+
+	stateV3 := newTestNodeStateWithOptions(t, Options{
+		DefaultStartupCost: 1,
+		StartedSignal:      StartedSignal{Type: StartedSignalContainersStarted},
+	}, clock.New(), pods, nil)
+
+	notReadyPods, err := stateV3.NotReadyPods("node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notReadyPods)
+}
+
+func TestShouldCountNotReadyPodsOfOwner(t *testing.T) {
+	pods := []v1.Pod{
+		withOwner(mockUnhealthyPod("rs-a-1", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"), "rs-a"),
+		withOwner(mockUnhealthyPod("rs-a-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-2"), "rs-a"),
+		withOwner(mockRunningPod("rs-a-3", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1"), "rs-a"),
+		withOwner(mockUnhealthyPod("rs-a-4", "ns-2", "9a2a4b63-35b4-4e0c-a6cb-c9ce0a3c1b0e", "node-1"), "rs-a"),
+		withOwner(mockUnhealthyPod("rs-b-1", "ns-1", "36847994-2dae-46e3-8ee5-af6afc2a5d63", "node-1"), "rs-b"),
+		mockUnhealthyPod("bare-pod", "ns-1", "d14b61cd-4a3a-477e-ac0a-2b2c50f301ee", "node-1"),
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+
+	reserved := withOwner(mockUnhealthyPod("rs-a-5", "ns-1", "33d30e5a-548d-4c89-9821-f18bc1f9df2c", ""), "rs-a")
+	stateV3.AddSchedulingPod(&reserved, "node-2")
+	observed := pods[0]
+	stateV3.AddSchedulingPod(&observed, "node-1")
+
+	ownerKey := OwnerKey(&pods[0])
+	assert.Equal(t, "ns-1/ReplicaSet/rs-a", ownerKey)
+	notReadyPods, err := stateV3.NotReadyOwnerPods(ownerKey, "node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notReadyPods)
+	notReadyPods, err = stateV3.NotReadyOwnerPods(ownerKey, "node-2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, notReadyPods)
+	notReadyPods, err = stateV3.NotReadyOwnerPodsClusterWide(ownerKey)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, notReadyPods)
+	notReadyPods, err = stateV3.NotReadyOwnerPodsClusterWide("ns-1/ReplicaSet/unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, notReadyPods)
+	assert.Equal(t, "", OwnerKey(&pods[5]))
+}
+
+func TestShouldCountNotReadyPodsOfSchedulerClusterWide(t *testing.T) {
+	pods := []v1.Pod{
+		withScheduler(mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"), "thundering-herd-scheduler"),
+		withScheduler(mockUnhealthyPod("test-pod-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-2"), "thundering-herd-scheduler"),
+		withScheduler(mockRunningPod("test-pod-3", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1"), "thundering-herd-scheduler"),
+		withScheduler(mockUnhealthyPod("test-pod-4", "ns-1", "9a2a4b63-35b4-4e0c-a6cb-c9ce0a3c1b0e", ""), "thundering-herd-scheduler"),
+		withScheduler(mockUnhealthyPod("test-pod-5", "ns-1", "36847994-2dae-46e3-8ee5-af6afc2a5d63", "node-1"), "default-scheduler"),
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+
+	reserved := pods[3]
+	stateV3.AddSchedulingPod(&reserved, "node-3")
+
+	notReadyPods, err := stateV3.NotReadyPodsClusterWide("thundering-herd-scheduler")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, notReadyPods)
+	notReadyPods, err = stateV3.NotReadyPodsClusterWide("default-scheduler")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notReadyPods)
+	notReadyPods, err = stateV3.NotReadyPodsClusterWide("")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, notReadyPods)
+}
+
+func TestShouldCountNotReadyPodsOfNamespace(t *testing.T) {
+	pods := []v1.Pod{
+		mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"),
+		mockUnhealthyPod("test-pod-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-2"),
+		mockRunningPod("test-pod-3", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1"),
+		mockUnhealthyPod("test-pod-4", "ns-1", "9a2a4b63-35b4-4e0c-a6cb-c9ce0a3c1b0e", ""),
+		mockUnhealthyPod("test-pod-5", "ns-2", "36847994-2dae-46e3-8ee5-af6afc2a5d63", "node-1"),
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+
+	reserved := pods[3]
+	stateV3.AddSchedulingPod(&reserved, "node-3")
+
+	notReadyPods, err := stateV3.NotReadyNamespacePods("ns-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, notReadyPods)
+	notReadyPods, err = stateV3.NotReadyNamespacePods("ns-2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notReadyPods)
+	notReadyPods, err = stateV3.NotReadyNamespacePods("ns-3")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, notReadyPods)
+}
+
+func TestShouldListStartingPodsOfNode(t *testing.T) {
+	pods := []v1.Pod{
+		mockUnhealthyPod("test-pod", "ns-1", "11b666eb-a361-4b4e-8953-f88224462564", "node-1"),
+		mockUnhealthyPod("test-pod-2", "ns-1", "a8c0c923-2d28-4e18-85c0-3023ad460d8e", "node-2"),
+		mockRunningPod("test-pod-3", "ns-1", "8fc4799d-8181-426a-8247-0371f9f6fbeb", "node-1"),
+		mockUnhealthyPod("test-pod-4", "ns-1", "9a2a4b63-35b4-4e0c-a6cb-c9ce0a3c1b0e", ""),
+	}
+
+	stateV3 := newTestNodeState(t, pods, nil)
+
+	reserved := pods[3]
+	stateV3.AddSchedulingPod(&reserved, "node-1")
+	// observed pods are not listed as reservation again
+	observed := pods[0]
+	stateV3.AddSchedulingPod(&observed, "node-1")
+
+	notReadyPods, scheduledPods, err := stateV3.StartingPods("node-1")
+	assert.NoError(t, err)
+	assert.Len(t, notReadyPods, 1)
+	assert.Equal(t, "test-pod", notReadyPods[0].Name)
+	assert.Len(t, scheduledPods, 1)
+	assert.Equal(t, "test-pod-4", scheduledPods[0].Name)
+
+	notReadyPods, scheduledPods, err = stateV3.StartingPods("node-3")
+	assert.NoError(t, err)
+	assert.Empty(t, notReadyPods)
+	assert.Empty(t, scheduledPods)
+}
+
+func TestShouldListNodeNames(t *testing.T) {
+	stateV3 := newTestNodeState(t, nil, []v1.Node{mockNode("node-2", nil), mockNode("node-1", nil)})
+
+	nodeNames, err := stateV3.NodeNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-1", "node-2"}, nodeNames)
+}
+
+func TestShouldCountFailedNodeLookups(t *testing.T) {
+	metrics.Register()
+	stateV3 := newTestNodeState(t, nil, []v1.Node{mockNode("node-1", nil)})
+	failedLookups, err := testutil.GetCounterMetricValue(metrics.DependencyErrors.WithLabelValues("nodestate"))
+	assert.NoError(t, err)
+
+	_, err = stateV3.Node("node-1")
+	assert.NoError(t, err)
+	_, err = stateV3.Node("unknown-node")
+	assert.Error(t, err)
+
+	actual, err := testutil.GetCounterMetricValue(metrics.DependencyErrors.WithLabelValues("nodestate"))
+	assert.NoError(t, err)
+	assert.Equal(t, failedLookups+1, actual)
+}
+
+func TestShouldDeleteMetricsOfDeletedNode(t *testing.T) {
+	metrics.Register()
+	node := mockNode("deleted-node", nil)
+	client := testclient.NewSimpleClientset(&node)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	_, err := NewNodeStateV3(informerFactory, testOptions)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	metrics.NodeStartingPods.WithLabelValues(node.Name).Set(2)
+	metrics.NodeAllowedStartingPods.WithLabelValues(node.Name).Set(3)
+	assert.Equal(t, 2, nodeSeries(t, node.Name))
+
+	err = client.CoreV1().Nodes().Delete(context.TODO(), node.Name, meta_v1.DeleteOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return nodeSeries(t, node.Name) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// nodeSeries counts the series of the node gauges with the label of the node
+func nodeSeries(t *testing.T, nodeName string) int {
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	count := 0
+	for _, family := range families {
+		if family.GetName() != "thundering_herd_node_starting_pods" && family.GetName() != "thundering_herd_node_allowed_starting_pods" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if testutil.LabelsMatch(m, map[string]string{"node": nodeName}) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func withScheduler(pod v1.Pod, schedulerName string) v1.Pod {
+	pod.Spec.SchedulerName = schedulerName
+	return pod
+}
+
+func withOwner(pod v1.Pod, replicaSet string) v1.Pod {
+	pod.OwnerReferences = []meta_v1.OwnerReference{
+		{
+			APIVersion: "apps/v1",
+			Kind:       "ReplicaSet",
+			Name:       replicaSet,
+			UID:        types.UID(replicaSet),
+			Controller: ptr.To(true),
+		},
+	}
+	return pod
+}
+
+func newTestNodeState(t *testing.T, pods []v1.Pod, nodes []v1.Node) *NodeStateV3 {
+	return newTestNodeStateWithOptions(t, testOptions, clock.New(), pods, nodes)
+}
+
+func newTestNodeStateWithOptions(t *testing.T, options Options, c clock.Clock, pods []v1.Pod, nodes []v1.Node) *NodeStateV3 {
+	client := testclient.NewSimpleClientset()
+	for _, pod := range pods {
+		_, err := client.CoreV1().Pods(pod.Namespace).Create(context.TODO(), &pod, meta_v1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	for _, node := range nodes {
+		_, err := client.CoreV1().Nodes().Create(context.TODO(), &node, meta_v1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	n, err := internalNewNodeStateV3(informerFactory, options, c)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	return n
+}
+
+func mockRunningPod(name string, namespace string, uuid string, nodeName string) v1.Pod {
+	objMeta := meta_v1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		UID:       types.UID(uuid),
+	}
+	p := v1.Pod{
+		Spec: v1.PodSpec{
+			NodeName: nodeName,
+		},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{
+				{
+					Status: v1.ConditionTrue,
+					Type:   v1.PodReady,
+				},
+			},
+		},
+	}
+	p.ObjectMeta = objMeta
+
+	return p
+}
+
+func mockUnhealthyPod(name string, namespace string, uuid string, nodeName string) v1.Pod {
+	objMeta := meta_v1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		UID:       types.UID(uuid),
+	}
+	p := v1.Pod{
+		Spec: v1.PodSpec{
+			NodeName: nodeName,
+		},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{},
+		},
+	}
+	p.ObjectMeta = objMeta
+
+	return p
+}
+
+func TestCalculateParallelStartingPodsPerCore(t *testing.T) {
+	testcases := []struct {
+		name        string
+		podsPerCore float64
+		cpu         string
+		expected    int
+	}{
+		{
+			name:        "1 pod per core, 2 cores",
+			podsPerCore: 1,
+			cpu:         "2",
+			expected:    2,
+		},
+		{
+			name:        "0.5 pod per core, 2 cores",
+			podsPerCore: 0.5,
+			cpu:         "2",
+			expected:    1,
+		},
+		{
+			name:        "0.4 pod per core, 2 cores",
+			podsPerCore: 0.4,
+			cpu:         "2",
+			expected:    1,
+		},
+		{
+			name:        "0.4 pod per core, 1.6 cores",
+			podsPerCore: 0.4,
+			cpu:         "1600m",
+			expected:    1,
+		},
+		{
+			name:        "0.3 pod per core, 1.6 cores",
+			podsPerCore: 0.3,
+			cpu:         "1600m",
+			expected:    1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := resource.MustParse(tc.cpu)
+			result := calculateParallelStartingPodsPerCore(tc.podsPerCore, &q)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestNotReadyPodsAllowedInParallel(t *testing.T) {
+	testcases := []struct {
+		name                        string
+		parallelStartingPodsPerNode *int
+		parallelStartingPodsPerCore *float64
+		nodeName                    string
+		nodeAllocatableCPU          *string
+		errExpected                 bool
+		expected                    int
+	}{
+		{
+			name:                        "parallelStartingPodsPerNode",
+			parallelStartingPodsPerNode: ptr.To(11),
+			parallelStartingPodsPerCore: nil,
+			nodeName:                    "node-1",
+			nodeAllocatableCPU:          ptr.To("2"),
+			errExpected:                 false,
+			expected:                    11,
+		},
+		{
+			name:                        "parallelStartingPodsPerCore",
+			parallelStartingPodsPerNode: nil,
+			parallelStartingPodsPerCore: ptr.To(2.0),
+			nodeName:                    "node-1",
+			nodeAllocatableCPU:          ptr.To("2"),
+			errExpected:                 false,
+			expected:                    4,
+		},
+		{
+			name:                        "parallelStartingPodsPerCore-fractions",
+			parallelStartingPodsPerNode: nil,
+			parallelStartingPodsPerCore: ptr.To(2.0),
+			nodeName:                    "node-1",
+			nodeAllocatableCPU:          ptr.To("1600m"),
+			errExpected:                 false,
+			expected:                    3,
+		},
+		{
+			name:                        "no limit",
+			parallelStartingPodsPerNode: nil,
+			parallelStartingPodsPerCore: nil,
+			nodeName:                    "node-1",
+			nodeAllocatableCPU:          ptr.To("2"),
+			errExpected:                 false,
+			expected:                    UnlimitedStartingPods,
+		},
+		{
+			name:                        "unknown node",
+			parallelStartingPodsPerNode: nil,
+			parallelStartingPodsPerCore: ptr.To(2.0),
+			nodeName:                    "node-2",
+			nodeAllocatableCPU:          ptr.To("2"),
+			errExpected:                 true,
+			expected:                    -1,
+		},
+		{
+			name:                        "no cpu resource",
+			parallelStartingPodsPerNode: nil,
+			parallelStartingPodsPerCore: ptr.To(2.0),
+			nodeName:                    "node-1",
+			nodeAllocatableCPU:          nil,
+			errExpected:                 false,
+			expected:                    1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes := []v1.Node{
+				mockNode("node-1", tc.nodeAllocatableCPU),
+			}
+			n := newTestNodeState(t, nil, nodes)
+
+			result, err := n.NotReadyPodsAllowedInParallel(tc.parallelStartingPodsPerNode, tc.parallelStartingPodsPerCore, tc.nodeName)
+			if tc.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestNotReadyPodsAllowedInParallelWithNodeAnnotations(t *testing.T) {
+	testcases := []struct {
+		name                        string
+		parallelStartingPodsPerNode *int
+		parallelStartingPodsPerCore *float64
+		annotations                 map[string]string
+		expected                    int
+	}{
+		{
+			name:                        "parallel starting pods overrides per node",
+			parallelStartingPodsPerNode: ptr.To(11),
+			annotations:                 map[string]string{ParallelStartingPodsAnnotation: "2"},
+			expected:                    2,
+		},
+		{
+			name:                        "parallel starting pods overrides per core",
+			parallelStartingPodsPerCore: ptr.To(1.0),
+			annotations:                 map[string]string{ParallelStartingPodsAnnotation: "2"},
+			expected:                    2,
+		},
+		{
+			name:                        "per core overrides per node",
+			parallelStartingPodsPerNode: ptr.To(11),
+			annotations:                 map[string]string{ParallelStartingPodsPerCoreAnnotation: "0.5"},
+			expected:                    2,
+		},
+		{
+			name:                        "parallel starting pods wins over per core",
+			parallelStartingPodsPerCore: ptr.To(1.0),
+			annotations: map[string]string{
+				ParallelStartingPodsAnnotation:        "7",
+				ParallelStartingPodsPerCoreAnnotation: "0.5",
+			},
+			expected: 7,
+		},
+		{
+			name:                        "malformed annotation falls back to arguments",
+			parallelStartingPodsPerCore: ptr.To(1.0),
+			annotations:                 map[string]string{ParallelStartingPodsAnnotation: "many"},
+			expected:                    4,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := mockNode("node-1", ptr.To("4"))
+			node.Annotations = tc.annotations
+			n := newTestNodeState(t, nil, []v1.Node{node})
+
+			result, err := n.NotReadyPodsAllowedInParallel(tc.parallelStartingPodsPerNode, tc.parallelStartingPodsPerCore, "node-1")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestShouldPickUpChangedNodeAnnotations(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	node := mockNode("node-1", ptr.To("4"))
+	_, err := client.CoreV1().Nodes().Create(context.TODO(), &node, meta_v1.CreateOptions{})
+	assert.NoError(t, err)
+
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	stateV3, err := NewNodeStateV3(informerFactory, testOptions)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	result, err := stateV3.NotReadyPodsAllowedInParallel(ptr.To(3), nil, "node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result)
+
+	node.Annotations = map[string]string{ParallelStartingPodsAnnotation: "1"}
+	_, err = client.CoreV1().Nodes().Update(context.TODO(), &node, meta_v1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		result, err = stateV3.NotReadyPodsAllowedInParallel(ptr.To(3), nil, "node-1")
+		return err == nil && result == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func mockContainer(cpuRequest *string, cpuLimit *string) v1.Container {
+	c := v1.Container{
+		Name: "test-container",
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{},
+			Limits:   v1.ResourceList{},
+		},
+	}
+	if cpuRequest != nil {
+		c.Resources.Requests[v1.ResourceCPU] = resource.MustParse(*cpuRequest)
+	}
+	if cpuLimit != nil {
+		c.Resources.Limits[v1.ResourceCPU] = resource.MustParse(*cpuLimit)
+	}
+	return c
+}
+
+func mockNode(nodeName string, allocatableCPU *string) v1.Node {
+	ret := v1.Node{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Node",
+			APIVersion: "v1",
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: nodeName,
+		},
+		Spec:   v1.NodeSpec{},
+		Status: v1.NodeStatus{},
+	}
+	if allocatableCPU != nil {
+		ret.Status.Allocatable = v1.ResourceList{
+			v1.ResourceCPU: resource.MustParse(*allocatableCPU),
+		}
+	}
+	return ret
+}
@@ -3,20 +3,27 @@ package podcounter
 import (
 	"context"
 	"encoding/json"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/metrics"
 	v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 	"strconv"
+	"time"
 )
 
 const Annotation = "ThunderingHerdScheduling/Count"
 
+// WaitedAnnotation keeps the sum of the wait durations of a pod, e.g. "1m15s"
+const WaitedAnnotation = "ThunderingHerdScheduling/Waited"
+
 type PodCounterInterface interface {
 	CurrentCounter(pod *v1.Pod) int
 	IncrementCounter(pod *v1.Pod) (int, error)
 	SetCounter(pod *v1.Pod, val int) error
+	WaitedDuration(pod *v1.Pod) time.Duration
+	AddWaitedDuration(pod *v1.Pod, d time.Duration) (time.Duration, error)
 }
 
 type Counter struct {
@@ -51,15 +58,42 @@ func (c Counter) IncrementCounter(pod *v1.Pod) (int, error) {
 }
 
 func (c Counter) SetCounter(pod *v1.Pod, val int) error {
+	return c.patchAnnotation(pod, Annotation, strconv.Itoa(val))
+}
+
+func (c Counter) WaitedDuration(pod *v1.Pod) time.Duration {
+	if strVal, exists := pod.Annotations[WaitedAnnotation]; exists {
+		val, err := time.ParseDuration(strVal)
+		if err != nil {
+			klog.ErrorS(err, "Failed to parse annotation", "annotation", WaitedAnnotation, "value", strVal, "pod", klog.KObj(pod))
+			return 0
+		}
+
+		return val
+	}
+
+	return 0
+}
+
+func (c Counter) AddWaitedDuration(pod *v1.Pod, d time.Duration) (time.Duration, error) {
+	waited := c.WaitedDuration(pod) + d
+	err := c.patchAnnotation(pod, WaitedAnnotation, waited.String())
+	return waited, err
+}
+
+func (c Counter) patchAnnotation(pod *v1.Pod, annotation string, val string) error {
 	patch := struct {
 		Metadata struct {
 			Annotations map[string]string `json:"annotations"`
 		} `json:"metadata"`
 	}{}
 	patch.Metadata.Annotations = map[string]string{}
-	patch.Metadata.Annotations[Annotation] = strconv.Itoa(val)
+	patch.Metadata.Annotations[annotation] = val
 	patchJson, _ := json.Marshal(patch)
 
 	_, err := c.client.CoreV1().Pods(pod.Namespace).Patch(context.TODO(), pod.Name, types.MergePatchType, patchJson, meta_v1.PatchOptions{})
+	if err != nil {
+		metrics.DependencyErrors.WithLabelValues("podcounter").Inc()
+	}
 	return err
 }
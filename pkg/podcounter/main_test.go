@@ -8,6 +8,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"testing"
+	"time"
 )
 
 func TestShouldReturnNullIfNoCounterSet(t *testing.T) {
@@ -101,6 +102,35 @@ func TestShouldIncrementCounterAndReturnValue(t *testing.T) {
 	}
 }
 
+func TestShouldAddWaitedDuration(t *testing.T) {
+	var clientset kubernetes.Interface
+	p := getCounterTestPod(true, "2")
+	clientset = fake.NewSimpleClientset(&p)
+
+	c := New(clientset)
+
+	if waited := c.WaitedDuration(&p); waited != 0 {
+		t.Errorf("Initial waited duration expected to be 0, but was %s", waited)
+	}
+
+	p.Annotations[WaitedAnnotation] = "1m"
+	waited, err := c.AddWaitedDuration(&p, 15*time.Second)
+	if err != nil {
+		t.Errorf("Failed to add waited duration with error %v", err)
+	}
+	if waited != 75*time.Second {
+		t.Errorf("Failed to add waited duration, expected 1m15s but got %s", waited)
+	}
+
+	resp, _ := clientset.CoreV1().Pods("test-namespace").Get(context.TODO(), "test-pod", meta_v1.GetOptions{})
+	if resp.Annotations[WaitedAnnotation] != "1m15s" {
+		t.Errorf("Failed to validate patched pod, expected 1m15s but got %s", resp.Annotations[WaitedAnnotation])
+	}
+	if resp.Annotations[Annotation] != "2" {
+		t.Errorf("Counter annotation expected to be kept, but got %s", resp.Annotations[Annotation])
+	}
+}
+
 func getCounterTestPod(counterEnabled bool, value string) v1.Pod {
 	p := v1.Pod{
 		ObjectMeta: meta_v1.ObjectMeta{
@@ -0,0 +1,212 @@
+package thunderingherdscheduling
+
+import (
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/metrics"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/nodestate"
+	v1 "k8s.io/api/core/v1"
+)
+
+// startupBudget describes the pods starting on a node and how many of them are allowed to start in parallel
+type startupBudget struct {
+	notReadyPods int
+	startingCost float64
+	// nodestate.UnlimitedStartingPods if only the startup cpu budget applies
+	maxAllowedStartingPods int
+	// only set if the startup cpu budget is configured
+	startingMilliCPU           int64
+	maxAllowedStartingMilliCPU *int64
+}
+
+// admits reports if a pod fits into the remaining budget of the node
+func (b startupBudget) admits(podCost float64, podMilliCPU int64) bool {
+	if b.limitsPods() && !fitsBudget(b.startingCost, podCost, float64(b.maxAllowedStartingPods)) {
+		return false
+	}
+	if b.maxAllowedStartingMilliCPU != nil && !fitsBudget(float64(b.startingMilliCPU), float64(podMilliCPU), float64(*b.maxAllowedStartingMilliCPU)) {
+		return false
+	}
+	return true
+}
+
+// limitsPods reports if the starting pods are limited, otherwise only the startup cpu budget applies
+func (b startupBudget) limitsPods() bool {
+	return b.maxAllowedStartingPods != nodestate.UnlimitedStartingPods
+}
+
+// observe exposes the starting pods of the node and how many are allowed to start in parallel as metrics
+func (b startupBudget) observe(nodeName string) {
+	metrics.NodeStartingPods.WithLabelValues(nodeName).Set(float64(b.notReadyPods))
+	if b.limitsPods() {
+		metrics.NodeAllowedStartingPods.WithLabelValues(nodeName).Set(float64(b.maxAllowedStartingPods))
+	}
+}
+
+// a pod exceeding the whole budget is admitted as soon as nothing else is starting on the node to prevent starvation
+func fitsBudget(used float64, requested float64, budget float64) bool {
+	if used+requested <= budget {
+		return true
+	}
+	return used <= 0 && budget > 0
+}
+
+func (t *ThunderingHerdScheduling) nodeStartupBudget(nodeName string, args *ThunderingHerdSchedulingArgs) (startupBudget, error) {
+	var budget startupBudget
+	var err error
+	if budget.maxAllowedStartingPods, err = t.nodestate.NotReadyPodsAllowedInParallel(args.ParallelStartingPodsPerNode, args.ParallelStartingPodsPerCore, nodeName); err != nil {
+		return budget, err
+	}
+	if budget.notReadyPods, err = t.nodestate.NotReadyPods(nodeName); err != nil {
+		return budget, err
+	}
+	if budget.startingCost, err = t.nodestate.NotReadyPodsCost(nodeName); err != nil {
+		return budget, err
+	}
+	budget.observe(nodeName)
+	if args.StartupCPUFraction == nil {
+		return budget, nil
+	}
+
+	maxAllowedStartingMilliCPU, err := t.nodestate.MilliCPUAllowedInParallel(*args.StartupCPUFraction, nodeName)
+	if err != nil {
+		return budget, err
+	}
+	budget.maxAllowedStartingMilliCPU = &maxAllowedStartingMilliCPU
+	budget.startingMilliCPU, err = t.nodestate.NotReadyPodsMilliCPU(nodeName)
+	return budget, err
+}
+
+func (t *ThunderingHerdScheduling) admits(budget startupBudget, p *v1.Pod) bool {
+	return budget.admits(t.nodestate.StartupCost(p), t.nodestate.StartupMilliCPU(p))
+}
+
+// admitsOwner reports if the pod fits into the starting pods allowed for its controller owner on the node
+func (t *ThunderingHerdScheduling) admitsOwner(p *v1.Pod, nodeName string) (bool, error) {
+	ownerKey := nodestate.OwnerKey(p)
+	if ownerKey == "" || t.args.MaxStartingPodsPerOwnerPerNode == nil {
+		return true, nil
+	}
+	notReadyPods, err := t.nodestate.NotReadyOwnerPods(ownerKey, nodeName)
+	if err != nil {
+		return false, err
+	}
+	return notReadyPods < *t.args.MaxStartingPodsPerOwnerPerNode, nil
+}
+
+// admitsOwnerClusterWide reports if the pod fits into the starting pods allowed for its controller owner on all nodes
+func (t *ThunderingHerdScheduling) admitsOwnerClusterWide(p *v1.Pod) (bool, error) {
+	ownerKey := nodestate.OwnerKey(p)
+	if ownerKey == "" || t.args.MaxStartingPodsPerOwnerClusterWide == nil {
+		return true, nil
+	}
+	notReadyPods, err := t.nodestate.NotReadyOwnerPodsClusterWide(ownerKey)
+	if err != nil {
+		return false, err
+	}
+	return notReadyPods < *t.args.MaxStartingPodsPerOwnerClusterWide, nil
+}
+
+// admitsNamespace reports if the pod fits into the starting pods allowed for its namespace on all nodes
+func (t *ThunderingHerdScheduling) admitsNamespace(p *v1.Pod) (bool, error) {
+	if t.args.MaxStartingPodsPerNamespace == nil {
+		return true, nil
+	}
+	notReadyPods, err := t.nodestate.NotReadyNamespacePods(p.Namespace)
+	if err != nil {
+		return false, err
+	}
+	return notReadyPods < *t.args.MaxStartingPodsPerNamespace, nil
+}
+
+// admitsClusterWide reports if another pod is allowed to start on any node scheduled by this profile
+func (t *ThunderingHerdScheduling) admitsClusterWide() (bool, error) {
+	if t.args.MaxStartingPodsClusterWide == nil {
+		return true, nil
+	}
+	notReadyPods, err := t.nodestate.NotReadyPodsClusterWide(t.schedulerName)
+	if err != nil {
+		return false, err
+	}
+	return notReadyPods < *t.args.MaxStartingPodsClusterWide, nil
+}
+
+// limitsClusterWide reports if any budget independent of the node is configured
+func (t *ThunderingHerdScheduling) limitsClusterWide() bool {
+	return t.args.MaxStartingPodsPerOwnerClusterWide != nil || t.args.MaxStartingPodsPerNamespace != nil || t.args.MaxStartingPodsClusterWide != nil
+}
+
+// decision describes which of the budgets admit a pod
+type decision struct {
+	budget      startupBudget
+	nodeAdmits  bool
+	ownerAdmits bool
+	clusterWide clusterWideDecision
+}
+
+// clusterWideDecision describes which of the budgets independent of the node admit a pod
+type clusterWideDecision struct {
+	ownerAdmits     bool
+	namespaceAdmits bool
+	clusterAdmits   bool
+}
+
+func (d decision) admitted() bool {
+	return d.nodeAdmits && d.ownerAdmits && d.clusterWide.admitted()
+}
+
+func (d clusterWideDecision) admitted() bool {
+	return d.ownerAdmits && d.namespaceAdmits && d.clusterAdmits
+}
+
+// reason describes which budget doesn't admit the pod
+func (d decision) reason() string {
+	switch {
+	case !d.nodeAdmits:
+		return "node has no free startup budget"
+	case !d.ownerAdmits:
+		return "too many pods of the owner are starting on the node"
+	default:
+		return d.clusterWide.reason()
+	}
+}
+
+func (d clusterWideDecision) reason() string {
+	switch {
+	case !d.ownerAdmits:
+		return "too many pods of the owner are starting"
+	case !d.namespaceAdmits:
+		return "too many pods of the namespace are starting"
+	case !d.clusterAdmits:
+		return "too many pods are starting in the cluster"
+	default:
+		return ""
+	}
+}
+
+// decide checks the budgets of the node, of the owner of the pod and of the cluster
+func (t *ThunderingHerdScheduling) decide(p *v1.Pod, nodeName string, args *ThunderingHerdSchedulingArgs) (decision, error) {
+	var d decision
+	var err error
+	if d.budget, err = t.nodeStartupBudget(nodeName, args); err != nil {
+		return d, err
+	}
+	d.nodeAdmits = t.admits(d.budget, p)
+	if d.ownerAdmits, err = t.admitsOwner(p, nodeName); err != nil {
+		return d, err
+	}
+	d.clusterWide, err = t.decideClusterWide(p)
+	return d, err
+}
+
+// decideClusterWide checks the budgets which don't depend on the node the pod is scheduled onto
+func (t *ThunderingHerdScheduling) decideClusterWide(p *v1.Pod) (clusterWideDecision, error) {
+	var d clusterWideDecision
+	var err error
+	if d.ownerAdmits, err = t.admitsOwnerClusterWide(p); err != nil {
+		return d, err
+	}
+	if d.namespaceAdmits, err = t.admitsNamespace(p); err != nil {
+		return d, err
+	}
+	d.clusterAdmits, err = t.admitsClusterWide()
+	return d, err
+}
@@ -0,0 +1,128 @@
+package thunderingherdscheduling
+
+import (
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/nodestate"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+	"testing"
+)
+
+func TestStartupBudgetAdmits(t *testing.T) {
+	testcases := []struct {
+		name         string
+		startingCost float64
+		maxAllowed   int
+		podCost      float64
+		expected     bool
+	}{
+		{
+			name:         "free node",
+			startingCost: 0,
+			maxAllowed:   3,
+			podCost:      1,
+			expected:     true,
+		},
+		{
+			name:         "last slot",
+			startingCost: 2,
+			maxAllowed:   3,
+			podCost:      1,
+			expected:     true,
+		},
+		{
+			name:         "all slots in use",
+			startingCost: 3,
+			maxAllowed:   3,
+			podCost:      1,
+			expected:     false,
+		},
+		{
+			name:         "weighted pods fit",
+			startingCost: 2.5,
+			maxAllowed:   3,
+			podCost:      0.5,
+			expected:     true,
+		},
+		{
+			name:         "weighted pod exceeds remaining budget",
+			startingCost: 1,
+			maxAllowed:   3,
+			podCost:      2.5,
+			expected:     false,
+		},
+		{
+			name:         "pod exceeds whole budget on free node",
+			startingCost: 0,
+			maxAllowed:   2,
+			podCost:      4,
+			expected:     true,
+		},
+		{
+			name:         "no budget",
+			startingCost: 0,
+			maxAllowed:   0,
+			podCost:      1,
+			expected:     false,
+		},
+		{
+			name:         "unlimited pods",
+			startingCost: 20,
+			maxAllowed:   nodestate.UnlimitedStartingPods,
+			podCost:      1,
+			expected:     true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := startupBudget{
+				startingCost:           tc.startingCost,
+				maxAllowedStartingPods: tc.maxAllowed,
+			}
+			assert.Equal(t, tc.expected, b.admits(tc.podCost, 0))
+		})
+	}
+}
+
+func TestStartupBudgetAdmitsWithinCPUBudget(t *testing.T) {
+	testcases := []struct {
+		name             string
+		startingMilliCPU int64
+		maxAllowed       *int64
+		podMilliCPU      int64
+		expected         bool
+	}{
+		{
+			name:             "cpu budget disabled",
+			startingMilliCPU: 8000,
+			maxAllowed:       nil,
+			podMilliCPU:      2000,
+			expected:         true,
+		},
+		{
+			name:             "fits",
+			startingMilliCPU: 1000,
+			maxAllowed:       ptr.To(int64(3000)),
+			podMilliCPU:      2000,
+			expected:         true,
+		},
+		{
+			name:             "exceeds",
+			startingMilliCPU: 1000,
+			maxAllowed:       ptr.To(int64(3000)),
+			podMilliCPU:      2001,
+			expected:         false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := startupBudget{
+				maxAllowedStartingPods:     10,
+				startingMilliCPU:           tc.startingMilliCPU,
+				maxAllowedStartingMilliCPU: tc.maxAllowed,
+			}
+			assert.Equal(t, tc.expected, b.admits(1, tc.podMilliCPU))
+		})
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/backoff"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/nodestate"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
+	"net"
+	"time"
 )
 
 func ParseArguments(obj runtime.Object) (*ThunderingHerdSchedulingArgs, error) {
@@ -27,13 +31,94 @@ func ParseArguments(obj runtime.Object) (*ThunderingHerdSchedulingArgs, error) {
 		return nil, errors.New("cannot specify parallelStartingPodsPerNode and parallelStartingPodsPerCore at the same time")
 	}
 
+	if conf.DefaultStartupCost != nil && *conf.DefaultStartupCost < 0 {
+		return nil, errors.New("defaultStartupCost must not be negative")
+	}
+
+	if conf.StartupCPUFraction != nil && *conf.StartupCPUFraction <= 0 {
+		return nil, errors.New("startupCPUFraction must be greater than 0")
+	}
+
+	if err := conf.StartedSignalOptions().Validate(); err != nil {
+		return nil, err
+	}
+
+	if conf.MaxStartingSeconds != nil && *conf.MaxStartingSeconds < 0 {
+		return nil, errors.New("maxStartingSeconds must not be negative")
+	}
+
+	if conf.RestartingStartupCost != nil && *conf.RestartingStartupCost < 0 {
+		return nil, errors.New("restartingStartupCost must not be negative")
+	}
+
+	if conf.RestartingWindowSeconds != nil && *conf.RestartingWindowSeconds < 0 {
+		return nil, errors.New("restartingWindowSeconds must not be negative")
+	}
+
+	for i, rule := range conf.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid rule %d: %v", i, err)
+		}
+	}
+
+	if _, err := meta_v1.LabelSelectorAsSelector(conf.NamespaceSelector); err != nil {
+		return nil, fmt.Errorf("invalid namespaceSelector: %v", err)
+	}
+
+	if _, err := meta_v1.LabelSelectorAsSelector(conf.PodSelector); err != nil {
+		return nil, fmt.Errorf("invalid podSelector: %v", err)
+	}
+
+	if conf.MaxStartingPodsPerOwnerPerNode != nil && *conf.MaxStartingPodsPerOwnerPerNode <= 0 {
+		return nil, errors.New("maxStartingPodsPerOwnerPerNode must be greater than 0")
+	}
+
+	if conf.MaxStartingPodsPerOwnerClusterWide != nil && *conf.MaxStartingPodsPerOwnerClusterWide <= 0 {
+		return nil, errors.New("maxStartingPodsPerOwnerClusterWide must be greater than 0")
+	}
+
+	if conf.MaxStartingPodsClusterWide != nil && *conf.MaxStartingPodsClusterWide <= 0 {
+		return nil, errors.New("maxStartingPodsClusterWide must be greater than 0")
+	}
+
+	if conf.MaxStartingPodsPerNamespace != nil && *conf.MaxStartingPodsPerNamespace <= 0 {
+		return nil, errors.New("maxStartingPodsPerNamespace must be greater than 0")
+	}
+
+	if err := conf.BackoffStrategy().Validate(); err != nil {
+		return nil, err
+	}
+
+	if conf.MaxTotalWaitSeconds != nil && *conf.MaxTotalWaitSeconds < 0 {
+		return nil, errors.New("maxTotalWaitSeconds must not be negative")
+	}
+
+	if conf.FailurePolicy != nil {
+		if err := FailurePolicy(*conf.FailurePolicy).Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Mode != nil {
+		if err := Mode(*conf.Mode).Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.DebugAddress != nil {
+		if _, _, err := net.SplitHostPort(*conf.DebugAddress); err != nil {
+			return nil, fmt.Errorf("invalid debugAddress: %v", err)
+		}
+	}
+
 	//SetDefaultThunderingHerdArgs(conf)
 	return conf, nil
 }
 
 func SetDefaultThunderingHerdArgs(args *ThunderingHerdSchedulingArgs) {
 
-	if args.ParallelStartingPodsPerNode == nil && args.ParallelStartingPodsPerCore == nil {
+	// with the startup cpu budget the starting pods are only limited if configured explicitly
+	if args.ParallelStartingPodsPerNode == nil && args.ParallelStartingPodsPerCore == nil && args.StartupCPUFraction == nil {
 		defaultParallelPodsPerCore := 1.0
 		args.ParallelStartingPodsPerCore = &defaultParallelPodsPerCore
 	}
@@ -47,15 +132,133 @@ func SetDefaultThunderingHerdArgs(args *ThunderingHerdSchedulingArgs) {
 		defaultRetries := 5
 		args.MaxRetries = &defaultRetries
 	}
+
+	if args.DefaultStartupCost == nil {
+		defaultStartupCost := 1.0
+		args.DefaultStartupCost = &defaultStartupCost
+	}
+
+	if args.StartedSignal == nil {
+		defaultStartedSignal := string(nodestate.StartedSignalPodReady)
+		args.StartedSignal = &defaultStartedSignal
+	}
+
+	if args.ExcludeBackOffPods == nil {
+		defaultExcludeBackOffPods := true
+		args.ExcludeBackOffPods = &defaultExcludeBackOffPods
+	}
+
+	if args.RestartingWindowSeconds == nil {
+		defaultRestartingWindowSeconds := 300
+		args.RestartingWindowSeconds = &defaultRestartingWindowSeconds
+	}
+
+	if args.FailurePolicy == nil {
+		defaultFailurePolicy := string(FailurePolicyFailOpen)
+		args.FailurePolicy = &defaultFailurePolicy
+	}
+
+	if args.Mode == nil {
+		defaultMode := string(ModeWait)
+		args.Mode = &defaultMode
+	}
+
+	if args.FilterFallbackToPermit == nil {
+		defaultFilterFallbackToPermit := true
+		args.FilterFallbackToPermit = &defaultFilterFallbackToPermit
+	}
+
+	if args.Backoff == nil {
+		args.Backoff = &BackoffArgs{}
+	}
+
+	if args.Backoff.Strategy == nil {
+		defaultStrategy := string(backoff.StrategyLegacy)
+		args.Backoff.Strategy = &defaultStrategy
+	}
+
+	if args.Backoff.Factor == nil {
+		defaultFactor := defaultBackoffFactor
+		args.Backoff.Factor = &defaultFactor
+	}
 }
 
 type ThunderingHerdSchedulingArgs struct {
 	meta_v1.TypeMeta
 
-	ParallelStartingPodsPerNode *int     `json:"parallelStartingPodsPerNode"`
-	ParallelStartingPodsPerCore *float64 `json:"parallelStartingPodsPerCore"`
-	TimeoutSeconds              *int     `json:"timeoutSeconds"`
-	MaxRetries                  *int     `json:"maxRetries"`
+	ParallelStartingPodsPerNode        *int                   `json:"parallelStartingPodsPerNode"`
+	ParallelStartingPodsPerCore        *float64               `json:"parallelStartingPodsPerCore"`
+	TimeoutSeconds                     *int                   `json:"timeoutSeconds"`
+	MaxRetries                         *int                   `json:"maxRetries"`
+	DefaultStartupCost                 *float64               `json:"defaultStartupCost"`
+	StartupCPUFraction                 *float64               `json:"startupCPUFraction"`
+	StartedSignal                      *string                `json:"startedSignal"`
+	StartedConditionType               *string                `json:"startedConditionType"`
+	StartedAnnotation                  *string                `json:"startedAnnotation"`
+	MaxStartingSeconds                 *int                   `json:"maxStartingSeconds"`
+	ExcludeBackOffPods                 *bool                  `json:"excludeBackOffPods"`
+	RestartingStartupCost              *float64               `json:"restartingStartupCost"`
+	RestartingWindowSeconds            *int                   `json:"restartingWindowSeconds"`
+	Rules                              []NodeRule             `json:"rules"`
+	PriorityThreshold                  *int32                 `json:"priorityThreshold"`
+	NamespaceSelector                  *meta_v1.LabelSelector `json:"namespaceSelector"`
+	PodSelector                        *meta_v1.LabelSelector `json:"podSelector"`
+	MaxStartingPodsPerOwnerPerNode     *int                   `json:"maxStartingPodsPerOwnerPerNode"`
+	MaxStartingPodsPerOwnerClusterWide *int                   `json:"maxStartingPodsPerOwnerClusterWide"`
+	MaxStartingPodsClusterWide         *int                   `json:"maxStartingPodsClusterWide"`
+	MaxStartingPodsPerNamespace        *int                   `json:"maxStartingPodsPerNamespace"`
+	Backoff                            *BackoffArgs           `json:"backoff"`
+	MaxTotalWaitSeconds                *int                   `json:"maxTotalWaitSeconds"`
+	FailurePolicy                      *string                `json:"failurePolicy"`
+	FilterFallbackToPermit             *bool                  `json:"filterFallbackToPermit"`
+	Mode                               *string                `json:"mode"`
+	DebugAddress                       *string                `json:"debugAddress"`
+}
+
+const defaultBackoffFactor = 2.0
+
+// BackoffArgs configures how long a pod waits, timeoutSeconds is used as base of all strategies
+type BackoffArgs struct {
+	Strategy   *string  `json:"strategy"`
+	Factor     *float64 `json:"factor"`
+	MaxSeconds *int     `json:"maxSeconds"`
+}
+
+// BackoffStrategy converts the backoff arguments into the backoff representation,
+// the default factor is applied already as the arguments are validated before they are defaulted
+func (in *ThunderingHerdSchedulingArgs) BackoffStrategy() backoff.Strategy {
+	strategy := backoff.Strategy{Factor: defaultBackoffFactor}
+	if in.TimeoutSeconds != nil {
+		strategy.Base = time.Duration(*in.TimeoutSeconds) * time.Second
+	}
+	if in.Backoff == nil {
+		return strategy
+	}
+	if in.Backoff.Strategy != nil {
+		strategy.Type = backoff.StrategyType(*in.Backoff.Strategy)
+	}
+	if in.Backoff.Factor != nil {
+		strategy.Factor = *in.Backoff.Factor
+	}
+	if in.Backoff.MaxSeconds != nil {
+		strategy.Max = time.Duration(*in.Backoff.MaxSeconds) * time.Second
+	}
+	return strategy
+}
+
+// StartedSignalOptions converts the started signal arguments into the node state representation
+func (in *ThunderingHerdSchedulingArgs) StartedSignalOptions() nodestate.StartedSignal {
+	signal := nodestate.StartedSignal{}
+	if in.StartedSignal != nil {
+		signal.Type = nodestate.StartedSignalType(*in.StartedSignal)
+	}
+	if in.StartedConditionType != nil {
+		signal.ConditionType = *in.StartedConditionType
+	}
+	if in.StartedAnnotation != nil {
+		signal.Annotation = *in.StartedAnnotation
+	}
+	return signal
 }
 
 func (in *ThunderingHerdSchedulingArgs) PrintArgs() {
@@ -68,6 +271,63 @@ func (in *ThunderingHerdSchedulingArgs) PrintArgs() {
 	}
 	klog.Infof("TimeoutSeconds=%d", *in.TimeoutSeconds)
 	klog.Infof("MaxRetries=%d", *in.MaxRetries)
+	klog.Infof("DefaultStartupCost=%f", *in.DefaultStartupCost)
+	if in.StartupCPUFraction != nil {
+		klog.Infof("StartupCPUFraction=%f", *in.StartupCPUFraction)
+	}
+	klog.Infof("StartedSignal=%s", *in.StartedSignal)
+	if in.StartedConditionType != nil {
+		klog.Infof("StartedConditionType=%s", *in.StartedConditionType)
+	}
+	if in.StartedAnnotation != nil {
+		klog.Infof("StartedAnnotation=%s", *in.StartedAnnotation)
+	}
+	if in.MaxStartingSeconds != nil {
+		klog.Infof("MaxStartingSeconds=%d", *in.MaxStartingSeconds)
+	}
+	klog.Infof("ExcludeBackOffPods=%t", *in.ExcludeBackOffPods)
+	if in.RestartingStartupCost != nil {
+		klog.Infof("RestartingStartupCost=%f", *in.RestartingStartupCost)
+	}
+	klog.Infof("RestartingWindowSeconds=%d", *in.RestartingWindowSeconds)
+	for i, rule := range in.Rules {
+		klog.Infof("Rules[%d]=%s", i, meta_v1.FormatLabelSelector(rule.NodeSelector))
+	}
+	if in.PriorityThreshold != nil {
+		klog.Infof("PriorityThreshold=%d", *in.PriorityThreshold)
+	}
+	if in.NamespaceSelector != nil {
+		klog.Infof("NamespaceSelector=%s", meta_v1.FormatLabelSelector(in.NamespaceSelector))
+	}
+	if in.PodSelector != nil {
+		klog.Infof("PodSelector=%s", meta_v1.FormatLabelSelector(in.PodSelector))
+	}
+	if in.MaxStartingPodsPerOwnerPerNode != nil {
+		klog.Infof("MaxStartingPodsPerOwnerPerNode=%d", *in.MaxStartingPodsPerOwnerPerNode)
+	}
+	if in.MaxStartingPodsPerOwnerClusterWide != nil {
+		klog.Infof("MaxStartingPodsPerOwnerClusterWide=%d", *in.MaxStartingPodsPerOwnerClusterWide)
+	}
+	if in.MaxStartingPodsClusterWide != nil {
+		klog.Infof("MaxStartingPodsClusterWide=%d", *in.MaxStartingPodsClusterWide)
+	}
+	if in.MaxStartingPodsPerNamespace != nil {
+		klog.Infof("MaxStartingPodsPerNamespace=%d", *in.MaxStartingPodsPerNamespace)
+	}
+	klog.Infof("Backoff.Strategy=%s", *in.Backoff.Strategy)
+	klog.Infof("Backoff.Factor=%f", *in.Backoff.Factor)
+	if in.Backoff.MaxSeconds != nil {
+		klog.Infof("Backoff.MaxSeconds=%d", *in.Backoff.MaxSeconds)
+	}
+	if in.MaxTotalWaitSeconds != nil {
+		klog.Infof("MaxTotalWaitSeconds=%d", *in.MaxTotalWaitSeconds)
+	}
+	klog.Infof("FailurePolicy=%s", *in.FailurePolicy)
+	klog.Infof("FilterFallbackToPermit=%t", *in.FilterFallbackToPermit)
+	klog.Infof("Mode=%s", *in.Mode)
+	if in.DebugAddress != nil {
+		klog.Infof("DebugAddress=%s", *in.DebugAddress)
+	}
 }
 
 func (in *ThunderingHerdSchedulingArgs) DeepCopy() *ThunderingHerdSchedulingArgs {
@@ -94,5 +354,34 @@ func (in *ThunderingHerdSchedulingArgs) DeepCopyInto(out *ThunderingHerdScheduli
 	out.TimeoutSeconds = in.TimeoutSeconds
 	out.ParallelStartingPodsPerNode = in.ParallelStartingPodsPerNode
 	out.ParallelStartingPodsPerCore = in.ParallelStartingPodsPerCore
+	out.DefaultStartupCost = in.DefaultStartupCost
+	out.StartupCPUFraction = in.StartupCPUFraction
+	out.StartedSignal = in.StartedSignal
+	out.StartedConditionType = in.StartedConditionType
+	out.StartedAnnotation = in.StartedAnnotation
+	out.MaxStartingSeconds = in.MaxStartingSeconds
+	out.ExcludeBackOffPods = in.ExcludeBackOffPods
+	out.RestartingStartupCost = in.RestartingStartupCost
+	out.RestartingWindowSeconds = in.RestartingWindowSeconds
+	if in.Rules != nil {
+		out.Rules = make([]NodeRule, len(in.Rules))
+		copy(out.Rules, in.Rules)
+	}
+	out.PriorityThreshold = in.PriorityThreshold
+	out.NamespaceSelector = in.NamespaceSelector
+	out.PodSelector = in.PodSelector
+	out.MaxStartingPodsPerOwnerPerNode = in.MaxStartingPodsPerOwnerPerNode
+	out.MaxStartingPodsPerOwnerClusterWide = in.MaxStartingPodsPerOwnerClusterWide
+	out.MaxStartingPodsClusterWide = in.MaxStartingPodsClusterWide
+	out.MaxStartingPodsPerNamespace = in.MaxStartingPodsPerNamespace
+	if in.Backoff != nil {
+		b := *in.Backoff
+		out.Backoff = &b
+	}
+	out.MaxTotalWaitSeconds = in.MaxTotalWaitSeconds
+	out.FailurePolicy = in.FailurePolicy
+	out.FilterFallbackToPermit = in.FilterFallbackToPermit
+	out.Mode = in.Mode
+	out.DebugAddress = in.DebugAddress
 	return
 }
@@ -44,6 +44,143 @@ func TestParseArguments(t *testing.T) {
 			errExpected: true,
 			errMsg:      "cannot specify parallelStartingPodsPerNode and parallelStartingPodsPerCore at the same time",
 		},
+		{
+			name:  "defaultStartupCost",
+			input: `{"defaultStartupCost": 0.5}`,
+			expected: &ThunderingHerdSchedulingArgs{
+				DefaultStartupCost: ptr.To(0.5),
+			},
+			errExpected: false,
+		},
+		{
+			name:        "negative defaultStartupCost",
+			input:       `{"defaultStartupCost": -1}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "defaultStartupCost must not be negative",
+		},
+		{
+			name:  "startupCPUFraction",
+			input: `{"startupCPUFraction": 0.8}`,
+			expected: &ThunderingHerdSchedulingArgs{
+				StartupCPUFraction: ptr.To(0.8),
+			},
+			errExpected: false,
+		},
+		{
+			name:        "zero startupCPUFraction",
+			input:       `{"startupCPUFraction": 0}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "startupCPUFraction must be greater than 0",
+		},
+		{
+			name:  "startedSignal",
+			input: `{"startedSignal": "PodCondition", "startedConditionType": "example.com/Warm"}`,
+			expected: &ThunderingHerdSchedulingArgs{
+				StartedSignal:        ptr.To("PodCondition"),
+				StartedConditionType: ptr.To("example.com/Warm"),
+			},
+			errExpected: false,
+		},
+		{
+			name:        "startedSignal without annotation",
+			input:       `{"startedSignal": "Annotation"}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "started signal Annotation requires an annotation",
+		},
+		{
+			name:        "unknown startedSignal",
+			input:       `{"startedSignal": "Warm"}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "unknown started signal Warm",
+		},
+		{
+			name:  "exclusions",
+			input: `{"maxStartingSeconds": 600, "excludeBackOffPods": false, "restartingStartupCost": 0.5, "restartingWindowSeconds": 60}`,
+			expected: &ThunderingHerdSchedulingArgs{
+				MaxStartingSeconds:      ptr.To(600),
+				ExcludeBackOffPods:      ptr.To(false),
+				RestartingStartupCost:   ptr.To(0.5),
+				RestartingWindowSeconds: ptr.To(60),
+			},
+			errExpected: false,
+		},
+		{
+			name:        "negative maxStartingSeconds",
+			input:       `{"maxStartingSeconds": -1}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "maxStartingSeconds must not be negative",
+		},
+		{
+			name:  "backoff",
+			input: `{"backoff": {"strategy": "exponential", "factor": 1.5, "maxSeconds": 120}}`,
+			expected: &ThunderingHerdSchedulingArgs{
+				Backoff: &BackoffArgs{Strategy: ptr.To("exponential"), Factor: ptr.To(1.5), MaxSeconds: ptr.To(120)},
+			},
+			errExpected: false,
+		},
+		{
+			name:  "exponential backoff with default factor",
+			input: `{"backoff": {"strategy": "exponential"}}`,
+			expected: &ThunderingHerdSchedulingArgs{
+				Backoff: &BackoffArgs{Strategy: ptr.To("exponential")},
+			},
+			errExpected: false,
+		},
+		{
+			name:        "unknown backoff strategy",
+			input:       `{"backoff": {"strategy": "fibonacci"}}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "unknown backoff strategy fibonacci",
+		},
+		{
+			name:        "negative maxTotalWaitSeconds",
+			input:       `{"maxTotalWaitSeconds": -1}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "maxTotalWaitSeconds must not be negative",
+		},
+		{
+			name:        "unknown failure policy",
+			input:       `{"failurePolicy": "ignore"}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "unknown failure policy ignore",
+		},
+		{
+			name:        "invalid max starting pods per namespace",
+			input:       `{"maxStartingPodsPerNamespace": 0}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "maxStartingPodsPerNamespace must be greater than 0",
+		},
+		{
+			name:        "unknown mode",
+			input:       `{"mode": "block"}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "unknown mode block",
+		},
+		{
+			name:  "debugAddress",
+			input: `{"debugAddress": "127.0.0.1:10260"}`,
+			expected: &ThunderingHerdSchedulingArgs{
+				DebugAddress: ptr.To("127.0.0.1:10260"),
+			},
+			errExpected: false,
+		},
+		{
+			name:        "debugAddress without port",
+			input:       `{"debugAddress": "localhost"}`,
+			expected:    nil,
+			errExpected: true,
+			errMsg:      "invalid debugAddress: address localhost: missing port in address",
+		},
 		{
 			name:        "malformed",
 			input:       `wrong json`,
@@ -82,6 +219,14 @@ func TestSetDefaultThunderingHerdArgs(t *testing.T) {
 				ParallelStartingPodsPerCore: ptr.To(1.0),
 				TimeoutSeconds:              ptr.To(5),
 				MaxRetries:                  ptr.To(5),
+				DefaultStartupCost:          ptr.To(1.0),
+				StartedSignal:               ptr.To("PodReady"),
+				ExcludeBackOffPods:          ptr.To(true),
+				RestartingWindowSeconds:     ptr.To(300),
+				Backoff:                     &BackoffArgs{Strategy: ptr.To("legacy"), Factor: ptr.To(2.0)},
+				FailurePolicy:               ptr.To("failOpen"),
+				FilterFallbackToPermit:      ptr.To(true),
+				Mode:                        ptr.To("wait"),
 			},
 		},
 		{
@@ -90,11 +235,27 @@ func TestSetDefaultThunderingHerdArgs(t *testing.T) {
 				ParallelStartingPodsPerCore: ptr.To(2.0),
 				TimeoutSeconds:              ptr.To(3),
 				MaxRetries:                  ptr.To(4),
+				DefaultStartupCost:          ptr.To(0.5),
+				StartedSignal:               ptr.To("ContainersStarted"),
+				ExcludeBackOffPods:          ptr.To(false),
+				RestartingWindowSeconds:     ptr.To(60),
+				Backoff:                     &BackoffArgs{Strategy: ptr.To("exponential"), Factor: ptr.To(1.5), MaxSeconds: ptr.To(120)},
+				FailurePolicy:               ptr.To("failClosed"),
+				FilterFallbackToPermit:      ptr.To(false),
+				Mode:                        ptr.To("reject"),
 			},
 			expected: &ThunderingHerdSchedulingArgs{
 				ParallelStartingPodsPerCore: ptr.To(2.0),
 				TimeoutSeconds:              ptr.To(3),
 				MaxRetries:                  ptr.To(4),
+				DefaultStartupCost:          ptr.To(0.5),
+				StartedSignal:               ptr.To("ContainersStarted"),
+				ExcludeBackOffPods:          ptr.To(false),
+				RestartingWindowSeconds:     ptr.To(60),
+				Backoff:                     &BackoffArgs{Strategy: ptr.To("exponential"), Factor: ptr.To(1.5), MaxSeconds: ptr.To(120)},
+				FailurePolicy:               ptr.To("failClosed"),
+				FilterFallbackToPermit:      ptr.To(false),
+				Mode:                        ptr.To("reject"),
 			},
 		},
 		{
@@ -107,6 +268,35 @@ func TestSetDefaultThunderingHerdArgs(t *testing.T) {
 				ParallelStartingPodsPerCore: nil,
 				TimeoutSeconds:              ptr.To(5),
 				MaxRetries:                  ptr.To(5),
+				DefaultStartupCost:          ptr.To(1.0),
+				StartedSignal:               ptr.To("PodReady"),
+				ExcludeBackOffPods:          ptr.To(true),
+				RestartingWindowSeconds:     ptr.To(300),
+				Backoff:                     &BackoffArgs{Strategy: ptr.To("legacy"), Factor: ptr.To(2.0)},
+				FailurePolicy:               ptr.To("failOpen"),
+				FilterFallbackToPermit:      ptr.To(true),
+				Mode:                        ptr.To("wait"),
+			},
+		},
+		{
+			name: "StartupCPUFraction is set",
+			input: &ThunderingHerdSchedulingArgs{
+				StartupCPUFraction: ptr.To(0.5),
+			},
+			expected: &ThunderingHerdSchedulingArgs{
+				ParallelStartingPodsPerNode: nil,
+				ParallelStartingPodsPerCore: nil,
+				StartupCPUFraction:          ptr.To(0.5),
+				TimeoutSeconds:              ptr.To(5),
+				MaxRetries:                  ptr.To(5),
+				DefaultStartupCost:          ptr.To(1.0),
+				StartedSignal:               ptr.To("PodReady"),
+				ExcludeBackOffPods:          ptr.To(true),
+				RestartingWindowSeconds:     ptr.To(300),
+				Backoff:                     &BackoffArgs{Strategy: ptr.To("legacy"), Factor: ptr.To(2.0)},
+				FailurePolicy:               ptr.To("failOpen"),
+				FilterFallbackToPermit:      ptr.To(true),
+				Mode:                        ptr.To("wait"),
 			},
 		},
 	}
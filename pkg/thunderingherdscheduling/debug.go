@@ -0,0 +1,140 @@
+package thunderingherdscheduling
+
+import (
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/waitingpods"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sort"
+	"time"
+)
+
+// debugState is the state of a profile served by the debug endpoint
+type debugState struct {
+	Mode  Mode             `json:"mode"`
+	Nodes []nodeDebugState `json:"nodes"`
+	Error string           `json:"error,omitempty"`
+}
+
+// nodeDebugState describes the pods starting on a node, how many are allowed to start in parallel and the pods waiting for it
+type nodeDebugState struct {
+	Name                       string                 `json:"name"`
+	NotReadyPods               []string               `json:"notReadyPods"`
+	ScheduledPods              []string               `json:"scheduledPods"`
+	StartingCost               float64                `json:"startingCost"`
+	MaxAllowedStartingPods     *int                   `json:"maxAllowedStartingPods,omitempty"`
+	StartingMilliCPU           *int64                 `json:"startingMilliCPU,omitempty"`
+	MaxAllowedStartingMilliCPU *int64                 `json:"maxAllowedStartingMilliCPU,omitempty"`
+	WaitingPods                []waitingPodDebugState `json:"waitingPods"`
+	Error                      string                 `json:"error,omitempty"`
+}
+
+type waitingPodDebugState struct {
+	Pod      string    `json:"pod"`
+	Retries  int       `json:"retries"`
+	Since    time.Time `json:"since"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// debugState collects the state of the node, of all nodes known to the informer and with waiting pods if the node name is empty
+func (t *ThunderingHerdScheduling) debugState(nodeName string) interface{} {
+	state := debugState{
+		Mode:  t.mode(),
+		Nodes: []nodeDebugState{},
+	}
+
+	nodeNames := []string{nodeName}
+	if nodeName == "" {
+		var err error
+		if nodeNames, err = t.nodestate.NodeNames(); err != nil {
+			state.Error = err.Error()
+		}
+		nodeNames = mergeNodeNames(nodeNames, t.waiting.Nodes())
+	}
+
+	for _, n := range nodeNames {
+		state.Nodes = append(state.Nodes, t.nodeDebugState(n))
+	}
+	return state
+}
+
+func (t *ThunderingHerdScheduling) nodeDebugState(nodeName string) nodeDebugState {
+	state := nodeDebugState{
+		Name:        nodeName,
+		WaitingPods: t.waitingPodsDebugState(t.waiting.List(nodeName)),
+	}
+
+	notReadyPods, scheduledPods, err := t.nodestate.StartingPods(nodeName)
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	state.NotReadyPods = podNames(notReadyPods)
+	state.ScheduledPods = podNames(scheduledPods)
+
+	args, err := t.nodeArgs(nodeName)
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	budget, err := t.nodeStartupBudget(nodeName, args)
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	state.StartingCost = budget.startingCost
+	if budget.limitsPods() {
+		state.MaxAllowedStartingPods = &budget.maxAllowedStartingPods
+	}
+	if budget.maxAllowedStartingMilliCPU != nil {
+		state.StartingMilliCPU = &budget.startingMilliCPU
+		state.MaxAllowedStartingMilliCPU = budget.maxAllowedStartingMilliCPU
+	}
+	return state
+}
+
+func (t *ThunderingHerdScheduling) waitingPodsDebugState(waiting []waitingpods.WaitingPod) []waitingPodDebugState {
+	ret := make([]waitingPodDebugState, 0, len(waiting))
+	for _, w := range waiting {
+		ret = append(ret, waitingPodDebugState{
+			Pod:      klog.KObj(w.Pod).String(),
+			Retries:  t.counter.CurrentCounter(t.latestPod(w.Pod)),
+			Since:    w.Since,
+			Deadline: w.Deadline,
+		})
+	}
+	return ret
+}
+
+// latestPod looks up the pod in the informer cache, the waiting pod doesn't contain the retry counter patched at permit
+func (t *ThunderingHerdScheduling) latestPod(p *v1.Pod) *v1.Pod {
+	latest, err := t.pods.Pods(p.Namespace).Get(p.Name)
+	if err != nil || latest.UID != p.UID {
+		return p
+	}
+	return latest
+}
+
+func podNames(pods []*v1.Pod) []string {
+	ret := make([]string, 0, len(pods))
+	for _, p := range pods {
+		ret = append(ret, klog.KObj(p).String())
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// mergeNodeNames returns the sorted union of the node names
+func mergeNodeNames(a []string, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	ret := make([]string, 0, len(a)+len(b))
+	for _, nodeNames := range [][]string{a, b} {
+		for _, n := range nodeNames {
+			if !seen[n] {
+				seen[n] = true
+				ret = append(ret, n)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,81 @@
+package thunderingherdscheduling
+
+import (
+	"errors"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/podcounter"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+	"testing"
+	"time"
+)
+
+func TestShouldCollectDebugStateOfNodes(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	scheduler.counter = podcounter.New(nil)
+	nodeState := scheduler.nodestate.(*NodeStateTest)
+	notReady := getStartingPod("not-ready", "test-namespace", "uuid-1", true)
+	scheduled := getStartingPod("scheduled", "test-namespace", "uuid-2", true)
+	nodeState.startingPods = []*v1.Pod{&notReady}
+	nodeState.scheduledPods = []*v1.Pod{&scheduled}
+	nodeState.nodeNames = []string{"node-2", "node-1"}
+
+	// the retry counter is only patched into the pod known to the informer
+	waiting := getStartingPod("waiting", "test-namespace", "uuid-3", true)
+	latest := waiting.DeepCopy()
+	latest.Annotations = map[string]string{podcounter.Annotation: "2"}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(latest))
+	scheduler.pods = corelisters.NewPodLister(indexer)
+
+	deadline := time.Now().Add(time.Minute)
+	scheduler.waiting.Add(&waiting, "node-3", deadline)
+
+	state := scheduler.debugState("").(debugState)
+	assert.Equal(t, ModeWait, state.Mode)
+	assert.Empty(t, state.Error)
+	assert.Len(t, state.Nodes, 3)
+	assert.Equal(t, []string{"node-1", "node-2", "node-3"}, []string{state.Nodes[0].Name, state.Nodes[1].Name, state.Nodes[2].Name})
+
+	node := state.Nodes[2]
+	assert.Equal(t, []string{"test-namespace/not-ready"}, node.NotReadyPods)
+	assert.Equal(t, []string{"test-namespace/scheduled"}, node.ScheduledPods)
+	assert.Equal(t, ptr.To(3), node.MaxAllowedStartingPods)
+	assert.Equal(t, 2.0, node.StartingCost)
+	assert.Nil(t, node.MaxAllowedStartingMilliCPU)
+	assert.Len(t, node.WaitingPods, 1)
+	assert.Equal(t, "test-namespace/waiting", node.WaitingPods[0].Pod)
+	assert.Equal(t, 2, node.WaitingPods[0].Retries)
+	assert.Equal(t, deadline, node.WaitingPods[0].Deadline)
+	assert.Empty(t, state.Nodes[0].WaitingPods)
+}
+
+func TestShouldCollectDebugStateOfSingleNode(t *testing.T) {
+	scheduler := getTestingScheduler(0, 1, false)
+	scheduler.args.StartupCPUFraction = ptr.To(0.5)
+	nodeState := scheduler.nodestate.(*NodeStateTest)
+	nodeState.nodeNames = []string{"node-1", "node-2"}
+	nodeState.allocatableMilliCPU = 4000
+	nodeState.startingMilliCPU = 500
+
+	state := scheduler.debugState("node-2").(debugState)
+	assert.Len(t, state.Nodes, 1)
+	assert.Equal(t, "node-2", state.Nodes[0].Name)
+	assert.Equal(t, ptr.To(int64(500)), state.Nodes[0].StartingMilliCPU)
+	assert.Equal(t, ptr.To(int64(2000)), state.Nodes[0].MaxAllowedStartingMilliCPU)
+}
+
+func TestShouldReportDebugStateLookupErrors(t *testing.T) {
+	scheduler := getTestingScheduler(0, 1, false)
+	scheduler.nodestate.(*NodeStateTest).exception = errors.New("cache not synced")
+
+	state := scheduler.debugState("").(debugState)
+	assert.Equal(t, "cache not synced", state.Error)
+	assert.Empty(t, state.Nodes)
+
+	state = scheduler.debugState("node-1").(debugState)
+	assert.Len(t, state.Nodes, 1)
+	assert.Equal(t, "cache not synced", state.Nodes[0].Error)
+}
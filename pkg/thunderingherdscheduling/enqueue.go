@@ -0,0 +1,53 @@
+package thunderingherdscheduling
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/util"
+)
+
+var _ framework.EnqueueExtensions = &ThunderingHerdScheduling{}
+
+// EventsToRegister requeues pods rejected by the plugin as soon as startup budget becomes free,
+// the scheduling queue only passes updates of bound pods to the hint if the rejected pod has a matching affinity term,
+// so usually only pod deletions and node events requeue the pods
+func (t *ThunderingHerdScheduling) EventsToRegister() []framework.ClusterEventWithHint {
+	return []framework.ClusterEventWithHint{
+		{Event: framework.ClusterEvent{Resource: framework.Pod, ActionType: framework.Update | framework.Delete}, QueueingHintFn: t.isSchedulableAfterPodChange},
+		// new nodes and changed node rules, annotations or allocatable cpu can increase the startup budget
+		{Event: framework.ClusterEvent{Resource: framework.Node, ActionType: framework.Add | framework.UpdateNodeAllocatable | framework.UpdateNodeLabel | framework.UpdateNodeAnnotation}},
+	}
+}
+
+// isSchedulableAfterPodChange queues the pod if a starting pod started or disappeared and its node admits the pod now,
+// with budgets independent of the node a pod kept out of the queue by PreEnqueue is queued as soon as they admit the pod
+func (t *ThunderingHerdScheduling) isSchedulableAfterPodChange(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+	oldPod, newPod, err := util.As[*v1.Pod](oldObj, newObj)
+	if err != nil {
+		return framework.Queue, err
+	}
+	if oldPod == nil || !t.nodestate.IsPodStarting(oldPod) {
+		return framework.QueueSkip, nil
+	}
+	if newPod != nil && t.nodestate.IsPodStarting(newPod) {
+		return framework.QueueSkip, nil
+	}
+
+	nodeName := oldPod.Spec.NodeName
+	args, err := t.nodeArgs(nodeName)
+	if err != nil {
+		return framework.Queue, err
+	}
+	d, err := t.decide(pod, nodeName, args)
+	if err != nil {
+		return framework.Queue, err
+	}
+	if !d.admitted() && !(t.limitsClusterWide() && d.clusterWide.admitted()) {
+		logger.V(5).Info("Starting pod stopped counting, but its node doesn't admit the pod yet", "pod", klog.KObj(pod), "startedPod", klog.KObj(oldPod), "nodeName", nodeName, "reason", d.reason())
+		return framework.QueueSkip, nil
+	}
+
+	logger.V(5).Info("Starting pod stopped counting, the pod may be scheduled", "pod", klog.KObj(pod), "startedPod", klog.KObj(oldPod), "nodeName", nodeName)
+	return framework.Queue, nil
+}
@@ -0,0 +1,99 @@
+package thunderingherdscheduling
+
+import (
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+)
+
+func TestShouldQueuePodsAfterStartingPodChanged(t *testing.T) {
+	starting := getStartingPod("starting-pod", "test-namespace", "uuid-starting", true)
+	starting.Spec.NodeName = "test-node"
+	started := *starting.DeepCopy()
+	started.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+	unassigned := getStartingPod("unassigned-pod", "test-namespace", "uuid-unassigned", true)
+
+	testcases := []struct {
+		name         string
+		notReadyPods int
+		clusterWide  *int
+		oldObj       interface{}
+		newObj       interface{}
+		expected     framework.QueueingHint
+	}{
+		{
+			name:         "starting pod became ready on node with free budget",
+			notReadyPods: 2,
+			oldObj:       &starting,
+			newObj:       &started,
+			expected:     framework.Queue,
+		},
+		{
+			name:         "starting pod became ready on saturated node",
+			notReadyPods: 3,
+			oldObj:       &starting,
+			newObj:       &started,
+			expected:     framework.QueueSkip,
+		},
+		{
+			name:         "starting pod became ready on saturated node freeing cluster wide budget",
+			notReadyPods: 3,
+			clusterWide:  ptr.To(10),
+			oldObj:       &starting,
+			newObj:       &started,
+			expected:     framework.Queue,
+		},
+		{
+			name:         "starting pod is still starting",
+			notReadyPods: 2,
+			oldObj:       &starting,
+			newObj:       &starting,
+			expected:     framework.QueueSkip,
+		},
+		{
+			name:         "starting pod was deleted",
+			notReadyPods: 2,
+			oldObj:       &starting,
+			expected:     framework.Queue,
+		},
+		{
+			name:         "started pod was deleted",
+			notReadyPods: 2,
+			oldObj:       &started,
+			expected:     framework.QueueSkip,
+		},
+		{
+			name:         "unassigned pod was updated",
+			notReadyPods: 2,
+			oldObj:       &unassigned,
+			newObj:       &unassigned,
+			expected:     framework.QueueSkip,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, tc.notReadyPods, false)
+			scheduler.args.MaxStartingPodsClusterWide = tc.clusterWide
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			hint, err := scheduler.isSchedulableAfterPodChange(klog.Background(), &pod, tc.oldObj, tc.newObj)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, hint)
+		})
+	}
+}
+
+func TestShouldRegisterPodAndNodeEvents(t *testing.T) {
+	scheduler := getTestingScheduler(0, 0, false)
+
+	events := scheduler.EventsToRegister()
+	assert.Len(t, events, 2)
+	assert.Equal(t, framework.Pod, events[0].Event.Resource)
+	assert.Equal(t, framework.Update|framework.Delete, events[0].Event.ActionType)
+	assert.NotNil(t, events[0].QueueingHintFn)
+	assert.Equal(t, framework.Node, events[1].Event.Resource)
+}
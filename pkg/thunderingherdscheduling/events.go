@@ -0,0 +1,61 @@
+package thunderingherdscheduling
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	"time"
+)
+
+// reasons of the events recorded on the pods
+const (
+	EventReasonStartupThrottled     = "StartupThrottled"
+	EventReasonStartupSlotGranted   = "StartupSlotGranted"
+	EventReasonMaxRetriesExceeded   = "MaxRetriesExceeded"
+	EventReasonMaxTotalWaitExceeded = "MaxTotalWaitExceeded"
+
+	eventAction = "Scheduling"
+)
+
+// recordThrottled records that the pod waits at permit or is rejected to be scheduled again
+func (t *ThunderingHerdScheduling) recordThrottled(p *v1.Pod, nodeName string, d decision, waitTime time.Duration) {
+	note := fmt.Sprintf("Waiting %s for a starting slot on node %s", waitTime, nodeName)
+	if t.mode() == ModeReject {
+		note = fmt.Sprintf("Rejected to be scheduled again as node %s has no starting slot", nodeName)
+	}
+	t.recordEvent(p, v1.EventTypeNormal, EventReasonStartupThrottled, fmt.Sprintf("%s, %s: %s", note, startingPods(d), d.reason()))
+}
+
+// recordSlotGranted records that a waiting pod was allowed as a starting slot became free
+func (t *ThunderingHerdScheduling) recordSlotGranted(p *v1.Pod, nodeName string, d decision, waited time.Duration) {
+	t.recordEvent(p, v1.EventTypeNormal, EventReasonStartupSlotGranted,
+		fmt.Sprintf("Starting slot granted on node %s after waiting %s, %s", nodeName, waited.Round(time.Second), startingPods(d)))
+}
+
+// recordMaxRetriesExceeded records that the pod is scheduled without free starting slot as it had to wait too often
+func (t *ThunderingHerdScheduling) recordMaxRetriesExceeded(p *v1.Pod, nodeName string, d decision, retries int) {
+	t.recordEvent(p, v1.EventTypeWarning, EventReasonMaxRetriesExceeded,
+		fmt.Sprintf("Scheduled onto node %s without free starting slot after %d retries, %s", nodeName, retries, startingPods(d)))
+}
+
+// recordMaxTotalWaitExceeded records that the pod is scheduled without free starting slot as it had to wait too long
+func (t *ThunderingHerdScheduling) recordMaxTotalWaitExceeded(p *v1.Pod, nodeName string, d decision) {
+	t.recordEvent(p, v1.EventTypeWarning, EventReasonMaxTotalWaitExceeded,
+		fmt.Sprintf("Scheduled onto node %s without free starting slot after waiting %s in total, %s", nodeName, t.counter.WaitedDuration(p), startingPods(d)))
+}
+
+// recordEvent records an event on the pod, in shadow mode the event states that it wasn't applied
+func (t *ThunderingHerdScheduling) recordEvent(p *v1.Pod, eventType string, reason string, note string) {
+	if t.mode() == ModeShadow {
+		note = "Shadow mode, not applied: " + note
+	}
+	t.recorder.Eventf(p, nil, eventType, reason, eventAction, "%s", note)
+}
+
+func startingPods(d decision) string {
+	if !d.budget.limitsPods() {
+		return fmt.Sprintf("%d pods are not ready and use %dm of the %dm startup cpu allowed in parallel",
+			d.budget.notReadyPods, d.budget.startingMilliCPU, ptr.Deref(d.budget.maxAllowedStartingMilliCPU, 0))
+	}
+	return fmt.Sprintf("%d pods are not ready and %d are allowed to start in parallel", d.budget.notReadyPods, d.budget.maxAllowedStartingPods)
+}
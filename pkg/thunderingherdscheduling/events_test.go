@@ -0,0 +1,87 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShouldRecordEventsOnPermit(t *testing.T) {
+	testcases := []struct {
+		name          string
+		retryCounter  int
+		mode          *string
+		maxTotalWait  *int
+		expectedEvent string
+	}{
+		{
+			name:          "pod waits",
+			retryCounter:  0,
+			expectedEvent: "Normal StartupThrottled Waiting 25s for a starting slot on node test-node, 6 pods are not ready and 3 are allowed to start in parallel: node has no free startup budget",
+		},
+		{
+			name:          "pod is rejected",
+			retryCounter:  0,
+			mode:          ptr.To("reject"),
+			expectedEvent: "Normal StartupThrottled Rejected to be scheduled again as node test-node has no starting slot, 6 pods are not ready and 3 are allowed to start in parallel: node has no free startup budget",
+		},
+		{
+			name:          "max retries exceeded",
+			retryCounter:  5,
+			expectedEvent: "Warning MaxRetriesExceeded Scheduled onto node test-node without free starting slot after 5 retries, 6 pods are not ready and 3 are allowed to start in parallel",
+		},
+		{
+			name:          "max total wait exceeded",
+			retryCounter:  0,
+			maxTotalWait:  ptr.To(0),
+			expectedEvent: "Warning MaxTotalWaitExceeded Scheduled onto node test-node without free starting slot after waiting 0s in total, 6 pods are not ready and 3 are allowed to start in parallel",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(tc.retryCounter, 6, false)
+			scheduler.args.Mode = tc.mode
+			scheduler.args.MaxTotalWaitSeconds = tc.maxTotalWait
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+
+			recorder := scheduler.recorder.(*events.FakeRecorder)
+			assert.Len(t, recorder.Events, 1)
+			assert.Equal(t, tc.expectedEvent, <-recorder.Events)
+		})
+	}
+}
+
+func TestShouldRecordEventOnGrantedSlot(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	scheduler.handle = getTestingHandle(&pod)
+	scheduler.waiting.Add(&pod, "test-node", time.Now().Add(time.Minute))
+
+	scheduler.releaseWaitingPods(nil, "test-node")
+
+	recorder := scheduler.recorder.(*events.FakeRecorder)
+	assert.Len(t, recorder.Events, 1)
+	event := <-recorder.Events
+	assert.True(t, strings.HasPrefix(event, "Normal StartupSlotGranted Starting slot granted on node test-node after waiting 0s"), event)
+	assert.True(t, strings.HasSuffix(event, "2 pods are not ready and 3 are allowed to start in parallel"), event)
+}
+
+func TestShouldRecordNoteVerbatim(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	scheduler.recordEvent(&pod, v1.EventTypeNormal, EventReasonStartupThrottled, "100% of the budget is used by %d pods")
+
+	recorder := scheduler.recorder.(*events.FakeRecorder)
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal StartupThrottled 100% of the budget is used by %d pods", <-recorder.Events)
+}
@@ -0,0 +1,159 @@
+package thunderingherdscheduling
+
+import (
+	"fmt"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"time"
+)
+
+type FailurePolicy string
+
+const (
+	// FailurePolicyFailOpen schedules the pod as if it wasn't throttled
+	FailurePolicyFailOpen FailurePolicy = "failOpen"
+	// FailurePolicyFailClosed rejects the pod, it goes through the scheduling cycle again
+	FailurePolicyFailClosed FailurePolicy = "failClosed"
+	// FailurePolicyWaitAndRetry lets the pod wait for the backoff duration before it's checked again
+	FailurePolicyWaitAndRetry FailurePolicy = "waitAndRetry"
+)
+
+// failure sources
+const (
+	sourceNodeState  = "nodestate"
+	sourcePodCounter = "podcounter"
+	sourceNamespaces = "namespaces"
+)
+
+func (p FailurePolicy) Validate() error {
+	switch p {
+	case FailurePolicyFailOpen, FailurePolicyFailClosed, FailurePolicyWaitAndRetry:
+		return nil
+	default:
+		return fmt.Errorf("unknown failure policy %s", p)
+	}
+}
+
+// onFailure applies the failure policy to the permit decision of a pod whose dependency failed
+func (t *ThunderingHerdScheduling) onFailure(p *v1.Pod, nodeName string, source string, err error) (*framework.Status, time.Duration) {
+	policy := t.failurePolicy()
+	t.recordFailure(p, nodeName, source, policy, err)
+
+	switch policy {
+	case FailurePolicyFailClosed:
+		return framework.NewStatus(framework.Error, err.Error()), 0
+	case FailurePolicyWaitAndRetry:
+		return t.waitAndRetry(p, nodeName)
+	default:
+		return framework.NewStatus(framework.Success), 0
+	}
+}
+
+// waitAndRetry lets the pod wait for its next backoff duration, like a throttled pod it's scheduled anyway
+// after max retries or once the max total wait is exhausted, therefore a persistent failure doesn't block it forever
+func (t *ThunderingHerdScheduling) waitAndRetry(p *v1.Pod, nodeName string) (*framework.Status, time.Duration) {
+	args, err := t.nodeArgs(nodeName)
+	if err != nil {
+		args = t.args
+	}
+
+	counter, err := t.incrementCounter(p)
+	if err != nil {
+		// the retries are counted in memory while the retry counter of the pod can't be patched, so they are bounded anyway
+		klog.ErrorS(err, "Failed to increment retry counter of pod waiting for a failed dependency", "pod", klog.KObj(p))
+		counter = max(t.failedRetries[p.UID], t.counter.CurrentCounter(p)) + 1
+		t.failedRetries[p.UID] = counter
+	} else {
+		delete(t.failedRetries, p.UID)
+	}
+	metrics.Retries.Observe(float64(counter))
+
+	if counter > *args.MaxRetries {
+		klog.Warningf("Pod %s had to wait for a failed dependency on node %s for > max retries, scheduling it", klog.KObj(p), nodeName)
+		metrics.ForceAdmissions.WithLabelValues("maxRetries").Inc()
+		delete(t.failedRetries, p.UID)
+		return framework.NewStatus(framework.Success), 0
+	}
+
+	waitTime, ok := t.limitTotalWait(p, args.BackoffStrategy().Duration(counter))
+	if !ok {
+		klog.Warningf("Pod %s had to wait for a failed dependency on node %s for >= max total wait, scheduling it", klog.KObj(p), nodeName)
+		metrics.ForceAdmissions.WithLabelValues("maxTotalWait").Inc()
+		delete(t.failedRetries, p.UID)
+		return framework.NewStatus(framework.Success), 0
+	}
+	return framework.NewStatus(framework.Wait), waitTime
+}
+
+// forgetFailedRetries removes the retries counted in memory of a deleted pod
+func (t *ThunderingHerdScheduling) forgetFailedRetries(p *v1.Pod) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.failedRetries, p.UID)
+}
+
+// onReleaseFailure applies the failure policy to a waiting pod whose dependency failed while it was about to be released,
+// true is returned if the pod stopped waiting
+func (t *ThunderingHerdScheduling) onReleaseFailure(waitingPod framework.WaitingPod, nodeName string, source string, err error) bool {
+	p := waitingPod.GetPod()
+	policy := t.failurePolicy()
+	t.recordFailure(p, nodeName, source, policy, err)
+
+	switch policy {
+	case FailurePolicyFailClosed:
+		t.waiting.Remove(p.UID)
+		waitingPod.Reject(Name, err.Error())
+		return true
+	case FailurePolicyWaitAndRetry:
+		// the pod keeps waiting until it's released or its wait duration is over
+		return false
+	default:
+		t.waiting.Remove(p.UID)
+		t.nodestate.AddSchedulingPod(p, nodeName)
+		waitingPod.Allow(Name)
+		return true
+	}
+}
+
+func (t *ThunderingHerdScheduling) recordFailure(p *v1.Pod, nodeName string, source string, policy FailurePolicy, err error) {
+	klog.ErrorS(err, "Dependency failed, applying failure policy",
+		"pod", klog.KObj(p),
+		"nodeName", nodeName,
+		"source", source,
+		"failurePolicy", policy)
+	metrics.FailurePolicyApplied.WithLabelValues(source, string(policy)).Inc()
+}
+
+func (t *ThunderingHerdScheduling) failurePolicy() FailurePolicy {
+	if t.args.FailurePolicy == nil {
+		return FailurePolicyFailOpen
+	}
+	return FailurePolicy(*t.args.FailurePolicy)
+}
+
+// onScoreFailure applies the failure policy to the score of a node whose dependency failed, the node gets the lowest score
+// unless the policy fails closed
+func (t *ThunderingHerdScheduling) onScoreFailure(p *v1.Pod, nodeName string, source string, err error) (int64, *framework.Status) {
+	if t.failsClosed(p, nodeName, source, err) {
+		return 0, framework.AsStatus(err)
+	}
+	return 0, nil
+}
+
+// onFilterFailure applies the failure policy to the filter of a node or the pre enqueue check of a pod whose dependency failed,
+// the check passes unless the policy fails closed, a waiting pod is throttled at permit
+func (t *ThunderingHerdScheduling) onFilterFailure(p *v1.Pod, nodeName string, source string, err error) *framework.Status {
+	if t.failsClosed(p, nodeName, source, err) {
+		return framework.AsStatus(err)
+	}
+	return nil
+}
+
+func (t *ThunderingHerdScheduling) failsClosed(p *v1.Pod, nodeName string, source string, err error) bool {
+	policy := t.failurePolicy()
+	t.recordFailure(p, nodeName, source, policy, err)
+	return policy == FailurePolicyFailClosed
+}
@@ -0,0 +1,209 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"errors"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/podcounter"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+	"time"
+)
+
+func TestShouldApplyFailurePolicyOnPermit(t *testing.T) {
+	testcases := []struct {
+		name             string
+		failurePolicy    *string
+		nodeStateFails   bool
+		podCounterFails  bool
+		expected         framework.Code
+		expectedWait     time.Duration
+		expectedNotReady int
+	}{
+		{
+			name:             "nodestate fails open by default",
+			nodeStateFails:   true,
+			expected:         framework.Success,
+			expectedNotReady: 7,
+		},
+		{
+			name:             "nodestate fails closed",
+			failurePolicy:    ptr.To("failClosed"),
+			nodeStateFails:   true,
+			expected:         framework.Error,
+			expectedNotReady: 6,
+		},
+		{
+			name:             "nodestate waits and retries",
+			failurePolicy:    ptr.To("waitAndRetry"),
+			nodeStateFails:   true,
+			expected:         framework.Wait,
+			expectedWait:     50 * time.Second,
+			expectedNotReady: 6,
+		},
+		{
+			name:             "podcounter fails open",
+			failurePolicy:    ptr.To("failOpen"),
+			podCounterFails:  true,
+			expected:         framework.Success,
+			expectedNotReady: 7,
+		},
+		{
+			name:             "podcounter fails closed",
+			failurePolicy:    ptr.To("failClosed"),
+			podCounterFails:  true,
+			expected:         framework.Error,
+			expectedNotReady: 6,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(1, 6, false)
+			scheduler.args.FailurePolicy = tc.failurePolicy
+			if tc.nodeStateFails {
+				scheduler.nodestate.(*NodeStateTest).exception = errors.New("lookup failed")
+			}
+			if tc.podCounterFails {
+				scheduler.counter = PodCounterTest{counter: 1, exception: errors.New("patch failed")}
+			}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, waitTime := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+			assert.Equal(t, tc.expectedWait, waitTime)
+			assert.Equal(t, tc.expectedNotReady, scheduler.nodestate.(*NodeStateTest).notReadyPods)
+		})
+	}
+}
+
+func TestShouldScheduleAnywayIfDependencyFailsPersistently(t *testing.T) {
+	testcases := []struct {
+		name          string
+		maxTotalWait  *int
+		expectedWaits []time.Duration
+	}{
+		{
+			name:          "max retries",
+			expectedWaits: []time.Duration{25 * time.Second, 50 * time.Second, 75 * time.Second, 100 * time.Second, 125 * time.Second},
+		},
+		{
+			name:          "max total wait",
+			maxTotalWait:  ptr.To(60),
+			expectedWaits: []time.Duration{25 * time.Second, 35 * time.Second},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			client := testclient.NewSimpleClientset(&pod)
+			scheduler := getTestingScheduler(0, 6, false)
+			scheduler.args.FailurePolicy = ptr.To("waitAndRetry")
+			scheduler.args.MaxTotalWaitSeconds = tc.maxTotalWait
+			scheduler.counter = podcounter.New(client)
+			scheduler.nodestate.(*NodeStateTest).exception = errors.New("lookup failed")
+			scheduler.handle = getTestingHandle()
+
+			for i, expectedWait := range tc.expectedWaits {
+				// every scheduling cycle sees the pod with the annotations patched in the previous cycles
+				latest := getPod(t, client, pod)
+				resp, waitTime := scheduler.Permit(context.TODO(), &framework.CycleState{}, latest, "test-node")
+				assert.Equal(t, framework.Wait, resp.Code(), "retry %d", i+1)
+				assert.Equal(t, expectedWait, waitTime, "retry %d", i+1)
+
+				// the wait times out, its waited duration is recorded synchronously to not race with the next cycle
+				w := scheduler.waiting.List("test-node")[0]
+				w.Deadline = time.Now()
+				w.Since = w.Deadline.Add(-waitTime)
+				scheduler.waiting.Remove(latest.UID)
+				_, err := scheduler.counter.AddWaitedDuration(latest, w.Waited())
+				assert.NoError(t, err)
+			}
+
+			resp, _ := scheduler.Permit(context.TODO(), &framework.CycleState{}, getPod(t, client, pod), "test-node")
+			assert.Equal(t, framework.Success, resp.Code())
+		})
+	}
+}
+
+func TestShouldCountRetriesInMemoryIfRetryCounterCantBePatched(t *testing.T) {
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	scheduler := getTestingScheduler(0, 6, false)
+	scheduler.args.FailurePolicy = ptr.To("waitAndRetry")
+	scheduler.counter = PodCounterTest{exception: errors.New("patch failed")}
+	scheduler.handle = getTestingHandle()
+
+	for i := 1; i <= *scheduler.args.MaxRetries; i++ {
+		resp, waitTime := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+		assert.Equal(t, framework.Wait, resp.Code(), "retry %d", i)
+		assert.Equal(t, time.Duration(i)*25*time.Second, waitTime, "retry %d", i)
+		scheduler.waiting.Remove(pod.UID)
+	}
+
+	resp, _ := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+	assert.Equal(t, framework.Success, resp.Code())
+	assert.Empty(t, scheduler.failedRetries)
+}
+
+func TestShouldForgetRetriesInMemoryOfDeletedPod(t *testing.T) {
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	scheduler := getTestingScheduler(0, 6, false)
+	scheduler.args.FailurePolicy = ptr.To("waitAndRetry")
+	scheduler.counter = PodCounterTest{exception: errors.New("patch failed")}
+	scheduler.handle = getTestingHandle()
+
+	resp, _ := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+	assert.Equal(t, framework.Wait, resp.Code())
+	assert.Equal(t, map[types.UID]int{"uuid": 1}, scheduler.failedRetries)
+
+	scheduler.forgetFailedRetries(&pod)
+	assert.Empty(t, scheduler.failedRetries)
+}
+
+func TestShouldApplyFailurePolicyOnRelease(t *testing.T) {
+	testcases := []struct {
+		name             string
+		failurePolicy    *string
+		expectedAllowed  bool
+		expectedRejected bool
+		expectedWaiting  int
+	}{
+		{
+			name:            "fail open",
+			failurePolicy:   ptr.To("failOpen"),
+			expectedAllowed: true,
+		},
+		{
+			name:             "fail closed",
+			failurePolicy:    ptr.To("failClosed"),
+			expectedRejected: true,
+		},
+		{
+			name:            "wait and retry",
+			failurePolicy:   ptr.To("waitAndRetry"),
+			expectedWaiting: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 2, false)
+			scheduler.args.FailurePolicy = tc.failurePolicy
+			scheduler.nodestate.(*NodeStateTest).exception = errors.New("lookup failed")
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			handle := getTestingHandle(&pod)
+			scheduler.handle = handle
+			scheduler.waiting.Add(&pod, "test-node", time.Now().Add(time.Minute))
+
+			scheduler.releaseWaitingPods(nil, "test-node")
+
+			assert.Equal(t, tc.expectedAllowed, handle.waitingPods["uuid"].allowed)
+			assert.Equal(t, tc.expectedRejected, handle.waitingPods["uuid"].rejected)
+			assert.Len(t, scheduler.waiting.List("test-node"), tc.expectedWaiting)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+const (
+	// ErrReasonSaturated is the reason of nodes rejected by the filter, reasons are aggregated over all nodes by the scheduler
+	ErrReasonSaturated = "node(s) had no free startup budget"
+	// ErrReasonFallbackToPermit is the reason of a pod which waits at permit on its next attempt
+	ErrReasonFallbackToPermit = "all feasible nodes had no free startup budget, the pod waits at permit on its next attempt"
+)
+
+var _ framework.FilterPlugin = &ThunderingHerdScheduling{}
+var _ framework.PostFilterPlugin = &ThunderingHerdScheduling{}
+
+// Filter rejects nodes which have no free startup budget for the pod, so it's scheduled onto a node where it can start right away
+func (t *ThunderingHerdScheduling) Filter(_ context.Context, _ *framework.CycleState, p *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	nodeName := nodeInfo.Node().Name
+	if t.mode() == ModeShadow || t.fallsBackToPermit(p) {
+		return nil
+	}
+
+	reason, err := t.exemptionReason(p)
+	if err != nil {
+		return t.onFilterFailure(p, nodeName, sourceNamespaces, err)
+	}
+	if reason != "" {
+		return nil
+	}
+
+	args, err := t.nodeArgs(nodeName)
+	if err != nil {
+		return t.onFilterFailure(p, nodeName, sourceNodeState, err)
+	}
+	budget, err := t.nodeStartupBudget(nodeName, args)
+	if err != nil {
+		return t.onFilterFailure(p, nodeName, sourceNodeState, err)
+	}
+	if !t.admits(budget, p) {
+		// preemption doesn't free startup budget, therefore the node is unresolvable
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, ErrReasonSaturated)
+	}
+	return nil
+}
+
+// PostFilter falls back to waiting at permit if the filter rejected at least one node, as the filter runs after all other filters
+// a rejected node is feasible apart from its startup budget
+func (t *ThunderingHerdScheduling) PostFilter(_ context.Context, _ *framework.CycleState, p *v1.Pod, filteredNodeStatusMap framework.NodeToStatusMap) (*framework.PostFilterResult, *framework.Status) {
+	if !*t.args.FilterFallbackToPermit {
+		return nil, framework.NewStatus(framework.Unschedulable)
+	}
+
+	for _, status := range filteredNodeStatusMap {
+		if status.Plugin() == Name {
+			t.mutex.Lock()
+			t.fallbackPods[p.UID] = struct{}{}
+			t.mutex.Unlock()
+
+			klog.InfoS("All feasible nodes have no free startup budget, the pod waits at permit on its next attempt", "pod", klog.KObj(p))
+			return nil, framework.NewStatus(framework.Unschedulable, ErrReasonFallbackToPermit)
+		}
+	}
+
+	// other filters rejected all nodes, a previous fallback isn't needed anymore
+	t.forgetFallback(p)
+	return nil, framework.NewStatus(framework.Unschedulable)
+}
+
+// forgetFallback removes the fallback of the pod, e.g. as it was deleted before it reached permit
+func (t *ThunderingHerdScheduling) forgetFallback(p *v1.Pod) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.fallbackPods, p.UID)
+}
+
+func (t *ThunderingHerdScheduling) fallsBackToPermit(p *v1.Pod) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	_, ok := t.fallbackPods[p.UID]
+	return ok
+}
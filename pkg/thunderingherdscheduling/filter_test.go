@@ -0,0 +1,174 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+)
+
+func TestShouldFilterSaturatedNodes(t *testing.T) {
+	testcases := []struct {
+		name           string
+		notReadyPods   int
+		annotations    map[string]string
+		fallback       bool
+		failurePolicy  *string
+		nodeStateFails bool
+		expected       framework.Code
+	}{
+		{
+			name:         "free startup budget",
+			notReadyPods: 2,
+			expected:     framework.Success,
+		},
+		{
+			name:         "no free startup budget",
+			notReadyPods: 3,
+			expected:     framework.UnschedulableAndUnresolvable,
+		},
+		{
+			name:         "pod is not throttled",
+			notReadyPods: 3,
+			annotations:  map[string]string{SkipAnnotation: "true"},
+			expected:     framework.Success,
+		},
+		{
+			name:         "pod falls back to permit",
+			notReadyPods: 3,
+			fallback:     true,
+			expected:     framework.Success,
+		},
+		{
+			name:           "lookup fails open",
+			nodeStateFails: true,
+			expected:       framework.Success,
+		},
+		{
+			name:           "lookup fails closed",
+			failurePolicy:  ptr.To("failClosed"),
+			nodeStateFails: true,
+			expected:       framework.Error,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, tc.notReadyPods, false)
+			scheduler.args.FailurePolicy = tc.failurePolicy
+			if tc.nodeStateFails {
+				scheduler.nodestate.(*NodeStateTest).exception = errors.New("lookup failed")
+			}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			pod.Annotations = tc.annotations
+			if tc.fallback {
+				scheduler.fallbackPods[pod.UID] = struct{}{}
+			}
+
+			status := scheduler.Filter(context.TODO(), &framework.CycleState{}, &pod, getTestingNodeInfo("test-node"))
+			assert.Equal(t, tc.expected, status.Code())
+			if tc.expected == framework.UnschedulableAndUnresolvable {
+				assert.Equal(t, []string{ErrReasonSaturated}, status.Reasons())
+			}
+		})
+	}
+}
+
+func TestShouldFallBackToPermitIfAllFeasibleNodesAreSaturated(t *testing.T) {
+	testcases := []struct {
+		name             string
+		fallback         bool
+		previousFallback bool
+		statuses         framework.NodeToStatusMap
+		expectedFallback bool
+	}{
+		{
+			name:     "saturated feasible node",
+			fallback: true,
+			statuses: framework.NodeToStatusMap{
+				"node-1": framework.NewStatus(framework.UnschedulableAndUnresolvable, ErrReasonSaturated).WithPlugin(Name),
+				"node-2": framework.NewStatus(framework.Unschedulable, "taint").WithPlugin("TaintToleration"),
+			},
+			expectedFallback: true,
+		},
+		{
+			name:     "no feasible node",
+			fallback: true,
+			statuses: framework.NodeToStatusMap{
+				"node-1": framework.NewStatus(framework.Unschedulable, "taint").WithPlugin("TaintToleration"),
+			},
+			expectedFallback: false,
+		},
+		{
+			name:             "no feasible node after fallback",
+			fallback:         true,
+			previousFallback: true,
+			statuses: framework.NodeToStatusMap{
+				"node-1": framework.NewStatus(framework.Unschedulable, "taint").WithPlugin("TaintToleration"),
+			},
+			expectedFallback: false,
+		},
+		{
+			name:     "fallback disabled",
+			fallback: false,
+			statuses: framework.NodeToStatusMap{
+				"node-1": framework.NewStatus(framework.UnschedulableAndUnresolvable, ErrReasonSaturated).WithPlugin(Name),
+			},
+			expectedFallback: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 3, false)
+			scheduler.args.FilterFallbackToPermit = ptr.To(tc.fallback)
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			if tc.previousFallback {
+				scheduler.fallbackPods[pod.UID] = struct{}{}
+			}
+
+			result, status := scheduler.PostFilter(context.TODO(), &framework.CycleState{}, &pod, tc.statuses)
+			assert.Nil(t, result)
+			assert.Equal(t, framework.Unschedulable, status.Code())
+			assert.Equal(t, tc.expectedFallback, scheduler.fallsBackToPermit(&pod))
+		})
+	}
+}
+
+func TestShouldWaitAtPermitAfterFallback(t *testing.T) {
+	scheduler := getTestingScheduler(0, 3, false)
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	scheduler.PostFilter(context.TODO(), &framework.CycleState{}, &pod, framework.NodeToStatusMap{
+		"test-node": framework.NewStatus(framework.UnschedulableAndUnresolvable, ErrReasonSaturated).WithPlugin(Name),
+	})
+
+	status := scheduler.Filter(context.TODO(), &framework.CycleState{}, &pod, getTestingNodeInfo("test-node"))
+	assert.True(t, status.IsSuccess())
+
+	status, _ = scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+	assert.Equal(t, framework.Wait, status.Code())
+	assert.False(t, scheduler.fallsBackToPermit(&pod))
+}
+
+func TestShouldForgetFallbackOfDeletedPod(t *testing.T) {
+	scheduler := getTestingScheduler(0, 3, false)
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	scheduler.PostFilter(context.TODO(), &framework.CycleState{}, &pod, framework.NodeToStatusMap{
+		"test-node": framework.NewStatus(framework.UnschedulableAndUnresolvable, ErrReasonSaturated).WithPlugin(Name),
+	})
+	assert.True(t, scheduler.fallsBackToPermit(&pod))
+
+	scheduler.forgetFallback(&pod)
+	assert.False(t, scheduler.fallsBackToPermit(&pod))
+	assert.Empty(t, scheduler.fallbackPods)
+}
+
+func getTestingNodeInfo(nodeName string) *framework.NodeInfo {
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: nodeName}})
+	return nodeInfo
+}
@@ -2,13 +2,19 @@ package thunderingherdscheduling
 
 import (
 	"context"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/debug"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/metrics"
 	"github.com/dbschenker/thundering-herd-scheduler/pkg/nodestate"
 	"github.com/dbschenker/thundering-herd-scheduler/pkg/podcounter"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/waitingpods"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
-	"math"
+	"k8s.io/utils/ptr"
 	"sync"
 	"time"
 )
@@ -18,20 +24,64 @@ const (
 )
 
 type ThunderingHerdScheduling struct {
-	counter   podcounter.PodCounterInterface
-	nodestate nodestate.NodeStateInterface
-	args      *ThunderingHerdSchedulingArgs
-	mutex     *sync.Mutex
+	handle        framework.Handle
+	schedulerName string
+	counter       podcounter.PodCounterInterface
+	nodestate     nodestate.NodeStateInterface
+	waiting       waitingpods.WaitingPodsInterface
+	namespaces    corelisters.NamespaceLister
+	// only set if the debug endpoint is enabled
+	pods     corelisters.PodLister
+	recorder events.EventRecorder
+	args     *ThunderingHerdSchedulingArgs
+	// pods which skip the filter and wait at permit as all feasible nodes had no free startup budget
+	fallbackPods map[types.UID]struct{}
+	// retries of pods waiting for a failed dependency whose retry counter couldn't be patched
+	failedRetries map[types.UID]int
+	mutex         *sync.Mutex
 }
 
 var _ framework.PermitPlugin = &ThunderingHerdScheduling{}
+var _ framework.ReservePlugin = &ThunderingHerdScheduling{}
+var _ framework.PostBindPlugin = &ThunderingHerdScheduling{}
+
+// Reserve is a no-op, the starting slot of a pod is only reserved once it's permitted
+func (t *ThunderingHerdScheduling) Reserve(_ context.Context, _ *framework.CycleState, _ *v1.Pod, _ string) *framework.Status {
+	return framework.NewStatus(framework.Success)
+}
+
+// Unreserve releases the starting slot right away as the pod was rejected or failed to bind
+func (t *ThunderingHerdScheduling) Unreserve(_ context.Context, _ *framework.CycleState, p *v1.Pod, nodeName string) {
+	// a pod still waiting was rejected or its wait timed out
+	if w, ok := t.waiting.Remove(p.UID); ok {
+		t.recordWaited(w)
+	}
+	t.mutex.Lock()
+	delete(t.fallbackPods, p.UID)
+	t.mutex.Unlock()
+	t.nodestate.RemoveSchedulingPod(p, nodeName)
+}
+
+// PostBind keeps the starting slot reserved until the bound pod is observed on the node
+func (t *ThunderingHerdScheduling) PostBind(_ context.Context, _ *framework.CycleState, p *v1.Pod, nodeName string) {
+	t.nodestate.BoundSchedulingPod(p, nodeName)
+}
 
 func (t *ThunderingHerdScheduling) Permit(_ context.Context, _ *framework.CycleState, p *v1.Pod, nodeName string) (*framework.Status, time.Duration) {
 	t.mutex.Lock()
 
+	// the fallback of the filter is only needed until the pod reached permit
+	delete(t.fallbackPods, p.UID)
 	status, duration := t.PermitInternal(p, nodeName)
+	metrics.PermitDecisions.WithLabelValues(status.Code().String(), string(t.mode())).Inc()
+	if t.mode() == ModeShadow && !status.IsSuccess() {
+		t.recordShadowDecision(p, nodeName, status, duration)
+		status, duration = framework.NewStatus(framework.Success), 0
+	}
 	if status.Code() == framework.Success {
 		t.nodestate.AddSchedulingPod(p, nodeName)
+	} else if status.Code() == framework.Wait {
+		t.waiting.Add(p, nodeName, time.Now().Add(duration))
 	}
 
 	t.mutex.Unlock()
@@ -39,46 +89,159 @@ func (t *ThunderingHerdScheduling) Permit(_ context.Context, _ *framework.CycleS
 }
 
 func (t *ThunderingHerdScheduling) PermitInternal(p *v1.Pod, nodeName string) (*framework.Status, time.Duration) {
-	notReadyPods := t.nodestate.NotReadyPods(nodeName)
-
-	maxAllowedStartingPods, err := t.nodestate.NotReadyPodsAllowedInParallel(t.args.ParallelStartingPodsPerNode, t.args.ParallelStartingPodsPerCore, nodeName)
-
-	klog.Infof("Node %s is allowed to start %d pods in parallel", nodeName, maxAllowedStartingPods)
+	reason, err := t.exemptionReason(p)
+	if err != nil {
+		return t.onFailure(p, nodeName, sourceNamespaces, err)
+	}
+	if reason != "" {
+		klog.InfoS("Pod is not throttled, scheduling it", "pod", klog.KObj(p), "reason", reason)
+		return framework.NewStatus(framework.Success), 0
+	}
 
+	args, err := t.nodeArgs(nodeName)
 	if err != nil {
-		return framework.NewStatus(framework.Error, err.Error()), 0
+		return t.onFailure(p, nodeName, sourceNodeState, err)
+	}
+	d, err := t.decide(p, nodeName, args)
+	if err != nil {
+		return t.onFailure(p, nodeName, sourceNodeState, err)
+	}
+
+	if d.budget.limitsPods() {
+		klog.Infof("Node %s is allowed to start %d pods in parallel", nodeName, d.budget.maxAllowedStartingPods)
 	}
 
-	if notReadyPods >= maxAllowedStartingPods {
-		counter, err := t.counter.IncrementCounter(p)
+	if !d.admitted() {
+		counter, err := t.incrementCounter(p)
 		if err != nil {
-			// to prevent any kind of issue with the scheduler
-			klog.ErrorS(err, "Failed to increase counter with error", "pod", klog.KObj(p))
-			return framework.NewStatus(framework.Success), 0
+			return t.onFailure(p, nodeName, sourcePodCounter, err)
 		}
+		metrics.Retries.Observe(float64(counter))
 
-		if counter > *t.args.MaxRetries {
+		if counter > *args.MaxRetries {
 			klog.Warning("Pod had to wait for > max retries, scheduling it", "pod", klog.KObj(p))
+			t.recordMaxRetriesExceeded(p, nodeName, d, counter-1)
+			metrics.ForceAdmissions.WithLabelValues("maxRetries").Inc()
 			return framework.NewStatus(framework.Success), 0
 		}
 
+		if t.mode() == ModeReject {
+			klog.InfoS("Pod is rejected to be scheduled again as there are already more pods not ready then allowed to start parallel",
+				"pod", klog.KObj(p),
+				"reason", d.reason(),
+				"maxAllowedStartingPods", d.budget.maxAllowedStartingPods,
+				"notReadyPods", d.budget.notReadyPods,
+				"nodeName", nodeName,
+				"retry", counter)
+			t.recordThrottled(p, nodeName, d, 0)
+			return framework.NewStatus(framework.Unschedulable, d.reason()), 0
+		}
+
 		// we need to wait
-		timeoutSeconds := t.args.TimeoutSeconds
-		waitTime := powInt(*timeoutSeconds, 2) * counter
+		waitTime, ok := t.limitTotalWait(p, args.BackoffStrategy().Duration(counter))
+		if !ok {
+			klog.Warningf("Pod %s had to wait for >= max total wait, scheduling it", klog.KObj(p))
+			t.recordMaxTotalWaitExceeded(p, nodeName, d)
+			metrics.ForceAdmissions.WithLabelValues("maxTotalWait").Inc()
+			return framework.NewStatus(framework.Success), 0
+		}
 
 		klog.Info("Pod has to wait as there are already more pods not ready then allowed to start parallel on node",
 			"pod", klog.KObj(p),
-			"maxAllowedStartingPods", maxAllowedStartingPods,
-			"notReadyPods", notReadyPods,
+			"maxAllowedStartingPods", d.budget.maxAllowedStartingPods,
+			"notReadyPods", d.budget.notReadyPods,
+			"startingCost", d.budget.startingCost,
+			"podCost", t.nodestate.StartupCost(p),
+			"startingMilliCPU", d.budget.startingMilliCPU,
+			"maxAllowedStartingMilliCPU", ptr.Deref(d.budget.maxAllowedStartingMilliCPU, -1),
+			"owner", nodestate.OwnerKey(p),
+			"reason", d.reason(),
 			"nodeName", nodeName,
 			"waitTime", waitTime)
+		t.recordThrottled(p, nodeName, d, waitTime)
+		metrics.WaitDuration.Observe(waitTime.Seconds())
 
-		return framework.NewStatus(framework.Wait), time.Duration(waitTime) * time.Second
+		return framework.NewStatus(framework.Wait), waitTime
 	} else {
 		return framework.NewStatus(framework.Success), 0
 	}
 }
 
+// releaseWaitingPods is called as soon as a starting pod on the node became ready or was removed
+func (t *ThunderingHerdScheduling) releaseWaitingPods(_ *v1.Pod, nodeName string) {
+	t.mutex.Lock()
+
+	// a started pod frees cluster wide budget for pods waiting on any node
+	nodeNames := []string{nodeName}
+	if t.limitsClusterWide() {
+		nodeNames = t.waiting.Nodes()
+	}
+	var ended []waitingpods.WaitingPod
+	for _, n := range nodeNames {
+		ended = append(ended, t.releaseWaitingPodsOnNode(n)...)
+	}
+
+	t.mutex.Unlock()
+	for _, w := range ended {
+		t.recordWaited(w)
+	}
+}
+
+// releaseWaitingPodsOnNode allows the waiting pods of a node in order as long as the node has free starting slots,
+// pods exceeding the budget of their owner are skipped. The pods which stopped waiting are returned.
+func (t *ThunderingHerdScheduling) releaseWaitingPodsOnNode(nodeName string) []waitingpods.WaitingPod {
+	var ended []waitingpods.WaitingPod
+	for _, w := range t.waiting.List(nodeName) {
+		waitingPod := t.handle.GetWaitingPod(w.Pod.UID)
+		if waitingPod == nil {
+			// the framework registers a waiting pod only after permit returned, so it's kept until its deadline passed
+			if time.Now().After(w.Deadline) {
+				if removed, ok := t.waiting.Remove(w.Pod.UID); ok {
+					ended = append(ended, removed)
+				}
+			}
+			continue
+		}
+
+		args, err := t.nodeArgs(nodeName)
+		if err != nil {
+			if t.onReleaseFailure(waitingPod, nodeName, sourceNodeState, err) {
+				ended = append(ended, w)
+			}
+			continue
+		}
+		d, err := t.decide(w.Pod, nodeName, args)
+		if err != nil {
+			if t.onReleaseFailure(waitingPod, nodeName, sourceNodeState, err) {
+				ended = append(ended, w)
+			}
+			continue
+		}
+		if !d.nodeAdmits || !d.clusterWide.clusterAdmits {
+			return ended
+		}
+		if !d.admitted() {
+			continue
+		}
+
+		t.waiting.Remove(w.Pod.UID)
+		ended = append(ended, w)
+		t.nodestate.AddSchedulingPod(w.Pod, nodeName)
+
+		klog.InfoS("Allow waiting pod as a starting slot on the node became free",
+			"pod", klog.KObj(w.Pod),
+			"maxAllowedStartingPods", d.budget.maxAllowedStartingPods,
+			"notReadyPods", d.budget.notReadyPods,
+			"startingCost", d.budget.startingCost,
+			"nodeName", nodeName)
+		t.recordSlotGranted(w.Pod, nodeName, d, time.Since(w.Since))
+		metrics.WaitedDuration.Observe(time.Since(w.Since).Seconds())
+
+		waitingPod.Allow(Name)
+	}
+	return ended
+}
+
 func (t *ThunderingHerdScheduling) Name() string {
 	return Name
 }
@@ -88,13 +251,52 @@ func New(_ context.Context, obj runtime.Object, handle framework.Handle) (framew
 	if err != nil {
 		return nil, err
 	}
+	// plugin args are passed as raw json, therefore the scheme defaulting is not applied
+	SetDefaultThunderingHerdArgs(args)
+
+	options := nodestate.Options{
+		DefaultStartupCost:    *args.DefaultStartupCost,
+		StartedSignal:         args.StartedSignalOptions(),
+		ExcludeBackOff:        *args.ExcludeBackOffPods,
+		RestartingStartupCost: args.RestartingStartupCost,
+		RestartingWindow:      time.Duration(*args.RestartingWindowSeconds) * time.Second,
+	}
+	if args.MaxStartingSeconds != nil {
+		options.MaxStartingDuration = time.Duration(*args.MaxStartingSeconds) * time.Second
+	}
+
+	metrics.Register()
+	state, err := nodestate.NewNodeStateV3(handle.SharedInformerFactory(), options)
+	if err != nil {
+		return nil, err
+	}
 
 	var m sync.Mutex
 	c := &ThunderingHerdScheduling{
-		counter:   podcounter.New(handle.ClientSet()),
-		args:      args,
-		nodestate: nodestate.NewNodeStateV2(handle.ClientSet()),
-		mutex:     &m,
+		handle:        handle,
+		recorder:      handle.EventRecorder(),
+		counter:       podcounter.New(handle.ClientSet()),
+		args:          args,
+		nodestate:     state,
+		waiting:       waitingpods.New(),
+		mutex:         &m,
+		fallbackPods:  make(map[types.UID]struct{}),
+		failedRetries: make(map[types.UID]int),
+	}
+	if profile, ok := handle.(interface{ ProfileName() string }); ok {
+		c.schedulerName = profile.ProfileName()
+	}
+	if args.NamespaceSelector != nil {
+		c.namespaces = handle.SharedInformerFactory().Core().V1().Namespaces().Lister()
+	}
+	state.AddPodStartedHandler(c.releaseWaitingPods)
+	state.AddPodDeletedHandler(c.forgetFallback)
+	state.AddPodDeletedHandler(c.forgetFailedRetries)
+	if args.DebugAddress != nil {
+		c.pods = handle.SharedInformerFactory().Core().V1().Pods().Lister()
+		if err := debug.Register(*args.DebugAddress, c.schedulerName, c.debugState); err != nil {
+			return nil, err
+		}
 	}
 
 	klog.Info("Registering Thundering Herd Scheduler")
@@ -102,7 +304,3 @@ func New(_ context.Context, obj runtime.Object, handle framework.Handle) (framew
 
 	return c, nil
 }
-
-func powInt(x, y int) int {
-	return int(math.Pow(float64(x), float64(y)))
-}
@@ -3,9 +3,13 @@ package thunderingherdscheduling
 import (
 	"context"
 	"errors"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/nodestate"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/waitingpods"
+	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"k8s.io/utils/ptr"
 	"sync"
@@ -135,6 +139,123 @@ func TestShouldReturnWaitBasedOnRetry(t *testing.T) {
 	}
 }
 
+func TestShouldReturnWaitBasedOnBackoffStrategy(t *testing.T) {
+	testcases := []struct {
+		name     string
+		backoff  *BackoffArgs
+		retry    int
+		expected time.Duration
+	}{
+		{
+			name:     "legacy",
+			backoff:  &BackoffArgs{Strategy: ptr.To("legacy")},
+			retry:    2,
+			expected: 75 * time.Second,
+		},
+		{
+			name:     "constant",
+			backoff:  &BackoffArgs{Strategy: ptr.To("constant")},
+			retry:    2,
+			expected: 5 * time.Second,
+		},
+		{
+			name:     "linear",
+			backoff:  &BackoffArgs{Strategy: ptr.To("linear")},
+			retry:    2,
+			expected: 15 * time.Second,
+		},
+		{
+			name:     "exponential with cap",
+			backoff:  &BackoffArgs{Strategy: ptr.To("exponential"), Factor: ptr.To(2.0), MaxSeconds: ptr.To(15)},
+			retry:    2,
+			expected: 15 * time.Second,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(tc.retry, 6, false)
+			scheduler.args.Backoff = tc.backoff
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, waitTime := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, framework.Wait, resp.Code())
+			assert.Equal(t, tc.expected, waitTime)
+		})
+	}
+}
+
+func TestShouldLimitTotalWait(t *testing.T) {
+	testcases := []struct {
+		name         string
+		maxTotalWait *int
+		annotation   string
+		waited       time.Duration
+		expected     framework.Code
+		expectedWait time.Duration
+	}{
+		{
+			name:         "no max total wait",
+			waited:       time.Hour,
+			expected:     framework.Wait,
+			expectedWait: 50 * time.Second,
+		},
+		{
+			name:         "wait fits into max total wait",
+			maxTotalWait: ptr.To(120),
+			waited:       25 * time.Second,
+			expected:     framework.Wait,
+			expectedWait: 50 * time.Second,
+		},
+		{
+			name:         "wait is shortened to remaining total wait",
+			maxTotalWait: ptr.To(60),
+			waited:       25 * time.Second,
+			expected:     framework.Wait,
+			expectedWait: 35 * time.Second,
+		},
+		{
+			name:         "max total wait exhausted",
+			maxTotalWait: ptr.To(60),
+			waited:       60 * time.Second,
+			expected:     framework.Success,
+		},
+		{
+			name:         "annotation takes precedence",
+			maxTotalWait: ptr.To(60),
+			annotation:   "300",
+			waited:       60 * time.Second,
+			expected:     framework.Wait,
+			expectedWait: 50 * time.Second,
+		},
+		{
+			name:         "invalid annotation",
+			maxTotalWait: ptr.To(60),
+			annotation:   "forever",
+			waited:       60 * time.Second,
+			expected:     framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(1, 6, false)
+			scheduler.counter = PodCounterTest{counter: 1, waited: tc.waited}
+			scheduler.args.MaxTotalWaitSeconds = tc.maxTotalWait
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			if tc.annotation != "" {
+				pod.Annotations = map[string]string{MaxTotalWaitAnnotation: tc.annotation}
+			}
+
+			resp, waitTime := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+			assert.Equal(t, tc.expectedWait, waitTime)
+		})
+	}
+}
+
 func TestShedulerShouldContinueIfCounterFails(t *testing.T) {
 	testcases := []struct {
 		name          string
@@ -194,12 +315,468 @@ func TestFulfillmentOfInterface(t *testing.T) {
 	}
 }
 
+func TestShouldScheduleDirectlyAsStartupCostFitsIntoBudget(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	scheduler.nodestate.(*NodeStateTest).startupCost = ptr.To(0.5)
+	state := &framework.CycleState{}
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Success, resp.Code())
+}
+
+func TestShouldReturnWaitAsStartupCostExceedsBudget(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	scheduler.nodestate.(*NodeStateTest).startupCost = ptr.To(1.5)
+	state := &framework.CycleState{}
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Wait, resp.Code())
+}
+
+func TestShouldConsiderStartupCPUBudget(t *testing.T) {
+	testcases := []struct {
+		name             string
+		startingMilliCPU int64
+		startupMilliCPU  int64
+		expected         framework.Code
+	}{
+		{
+			name:             "fits",
+			startingMilliCPU: 1000,
+			startupMilliCPU:  2000,
+			expected:         framework.Success,
+		},
+		{
+			name:             "exceeds",
+			startingMilliCPU: 1000,
+			startupMilliCPU:  2500,
+			expected:         framework.Wait,
+		},
+		{
+			name:             "exceeds on free node",
+			startingMilliCPU: 0,
+			startupMilliCPU:  6000,
+			expected:         framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 0, false)
+			scheduler.args.StartupCPUFraction = ptr.To(0.75)
+			nodeState := scheduler.nodestate.(*NodeStateTest)
+			nodeState.allocatableMilliCPU = 4000
+			nodeState.startingMilliCPU = tc.startingMilliCPU
+			nodeState.startupMilliCPU = tc.startupMilliCPU
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+		})
+	}
+}
+
+func TestShouldOnlyConsiderStartupCPUBudgetWithoutPodLimit(t *testing.T) {
+	testcases := []struct {
+		name             string
+		startingMilliCPU int64
+		expected         framework.Code
+	}{
+		{
+			name:             "fits",
+			startingMilliCPU: 2500,
+			expected:         framework.Success,
+		},
+		{
+			name:             "exceeds",
+			startingMilliCPU: 2600,
+			expected:         framework.Wait,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// far more pods are starting than the default of one pod per core would allow
+			scheduler := getTestingScheduler(0, 25, false)
+			scheduler.args.ParallelStartingPodsPerNode = nil
+			scheduler.args.StartupCPUFraction = ptr.To(0.75)
+			nodeState := scheduler.nodestate.(*NodeStateTest)
+			nodeState.allocatableMilliCPU = 4000
+			nodeState.startingMilliCPU = tc.startingMilliCPU
+			nodeState.startupMilliCPU = 500
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+		})
+	}
+}
+
+func TestShouldQueuePodOnWait(t *testing.T) {
+	scheduler := getTestingScheduler(0, 6, false)
+	state := &framework.CycleState{}
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Wait, resp.Code())
+
+	waiting := scheduler.waiting.List("test-node")
+	assert.Len(t, waiting, 1)
+	assert.Equal(t, types.UID("uuid"), waiting[0].Pod.UID)
+}
+
+func TestShouldRejectInsteadOfWaitInRejectMode(t *testing.T) {
+	testcases := []struct {
+		name           string
+		retryCounter   int
+		notReadyPods   int
+		expected       framework.Code
+		expectedReason string
+	}{
+		{
+			name:         "free startup budget",
+			retryCounter: 0,
+			notReadyPods: 2,
+			expected:     framework.Success,
+		},
+		{
+			name:           "no free startup budget",
+			retryCounter:   0,
+			notReadyPods:   3,
+			expected:       framework.Unschedulable,
+			expectedReason: "node has no free startup budget",
+		},
+		{
+			name:         "retry count exceeded",
+			retryCounter: 5,
+			notReadyPods: 3,
+			expected:     framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(tc.retryCounter, tc.notReadyPods, false)
+			scheduler.args.Mode = ptr.To("reject")
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, waitTime := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+			assert.Equal(t, time.Duration(0), waitTime)
+			assert.Empty(t, scheduler.waiting.List("test-node"))
+			if tc.expectedReason != "" {
+				assert.Equal(t, []string{tc.expectedReason}, resp.Reasons())
+			}
+		})
+	}
+}
+
+func TestShouldReleaseWaitingPodsWhileSlotsAreFree(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	pod1 := getStartingPod("pod-1", "test-namespace", "uuid-1", true)
+	pod2 := getStartingPod("pod-2", "test-namespace", "uuid-2", true)
+	handle := getTestingHandle(&pod1, &pod2)
+	scheduler.handle = handle
+
+	scheduler.waiting.Add(&pod1, "test-node", time.Now().Add(time.Minute))
+	scheduler.waiting.Add(&pod2, "test-node", time.Now().Add(time.Minute))
+
+	scheduler.releaseWaitingPods(nil, "test-node")
+
+	assert.True(t, handle.waitingPods["uuid-1"].allowed)
+	assert.False(t, handle.waitingPods["uuid-2"].allowed)
+	notReadyPods, err := scheduler.nodestate.NotReadyPods("test-node")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, notReadyPods)
+
+	waiting := scheduler.waiting.List("test-node")
+	assert.Len(t, waiting, 1)
+	assert.Equal(t, types.UID("uuid-2"), waiting[0].Pod.UID)
+}
+
+func TestShouldScheduleDirectlyAsPriorityIsAboveThreshold(t *testing.T) {
+	testcases := []struct {
+		name     string
+		priority *int32
+		expected framework.Code
+	}{
+		{
+			name:     "without priority",
+			priority: nil,
+			expected: framework.Wait,
+		},
+		{
+			name:     "below threshold",
+			priority: ptr.To(int32(999)),
+			expected: framework.Wait,
+		},
+		{
+			name:     "equal to threshold",
+			priority: ptr.To(int32(1000)),
+			expected: framework.Success,
+		},
+		{
+			name:     "system cluster critical",
+			priority: ptr.To(int32(2000000000)),
+			expected: framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 6, false)
+			scheduler.args.PriorityThreshold = ptr.To(int32(1000))
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			pod.Spec.Priority = tc.priority
+
+			resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+		})
+	}
+}
+
+func TestShouldReleaseWaitingPodsInPriorityOrder(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	pod1 := getStartingPod("pod-1", "test-namespace", "uuid-1", true)
+	pod2 := getStartingPod("pod-2", "test-namespace", "uuid-2", true)
+	pod2.Spec.Priority = ptr.To(int32(100))
+	handle := getTestingHandle(&pod1, &pod2)
+	scheduler.handle = handle
+
+	scheduler.waiting.Add(&pod1, "test-node", time.Now().Add(time.Minute))
+	scheduler.waiting.Add(&pod2, "test-node", time.Now().Add(time.Minute))
+
+	scheduler.releaseWaitingPods(nil, "test-node")
+
+	assert.False(t, handle.waitingPods["uuid-1"].allowed)
+	assert.True(t, handle.waitingPods["uuid-2"].allowed)
+}
+
+func TestShouldConsiderStartingPodsOfOwner(t *testing.T) {
+	testcases := []struct {
+		name         string
+		perNode      *int
+		clusterWide  *int
+		withoutOwner bool
+		expected     framework.Code
+	}{
+		{
+			name:     "no owner limits",
+			expected: framework.Success,
+		},
+		{
+			name:     "per node limit reached",
+			perNode:  ptr.To(1),
+			expected: framework.Wait,
+		},
+		{
+			name:     "per node limit not reached",
+			perNode:  ptr.To(2),
+			expected: framework.Success,
+		},
+		{
+			name:        "cluster wide limit reached",
+			clusterWide: ptr.To(3),
+			expected:    framework.Wait,
+		},
+		{
+			name:        "cluster wide limit not reached",
+			perNode:     ptr.To(2),
+			clusterWide: ptr.To(4),
+			expected:    framework.Success,
+		},
+		{
+			name:         "pod without owner",
+			perNode:      ptr.To(1),
+			clusterWide:  ptr.To(1),
+			withoutOwner: true,
+			expected:     framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 0, false)
+			scheduler.args.MaxStartingPodsPerOwnerPerNode = tc.perNode
+			scheduler.args.MaxStartingPodsPerOwnerClusterWide = tc.clusterWide
+			scheduler.nodestate.(*NodeStateTest).ownerNotReadyPods = map[string]map[string]int{
+				"test-namespace/ReplicaSet/test-rs": {"test-node": 1, "other-node": 2},
+			}
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			if !tc.withoutOwner {
+				pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "test-rs", Controller: ptr.To(true)}}
+			}
+
+			resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+		})
+	}
+}
+
+func TestShouldSkipWaitingPodsExceedingTheirOwnerLimit(t *testing.T) {
+	scheduler := getTestingScheduler(0, 0, false)
+	scheduler.args.MaxStartingPodsPerOwnerClusterWide = ptr.To(1)
+	scheduler.nodestate.(*NodeStateTest).ownerNotReadyPods = map[string]map[string]int{
+		"test-namespace/ReplicaSet/test-rs": {"other-node": 1},
+	}
+	pod1 := getStartingPod("pod-1", "test-namespace", "uuid-1", true)
+	pod1.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "test-rs", Controller: ptr.To(true)}}
+	pod2 := getStartingPod("pod-2", "test-namespace", "uuid-2", true)
+	pod3 := getStartingPod("pod-3", "test-namespace", "uuid-3", true)
+	handle := getTestingHandle(&pod1, &pod2, &pod3)
+	scheduler.handle = handle
+
+	scheduler.waiting.Add(&pod1, "test-node", time.Now().Add(time.Minute))
+	scheduler.waiting.Add(&pod2, "test-node", time.Now().Add(time.Minute))
+	scheduler.waiting.Add(&pod3, "another-node", time.Now().Add(time.Minute))
+
+	scheduler.releaseWaitingPods(nil, "other-node")
+
+	assert.False(t, handle.waitingPods["uuid-1"].allowed)
+	assert.True(t, handle.waitingPods["uuid-2"].allowed)
+	assert.True(t, handle.waitingPods["uuid-3"].allowed)
+}
+
+func TestShouldConsiderStartingPodsClusterWide(t *testing.T) {
+	testcases := []struct {
+		name                string
+		clusterNotReadyPods int
+		clusterWide         *int
+		expected            framework.Code
+	}{
+		{
+			name:                "no cluster wide limit",
+			clusterNotReadyPods: 100,
+			expected:            framework.Success,
+		},
+		{
+			name:                "cluster wide limit reached",
+			clusterNotReadyPods: 10,
+			clusterWide:         ptr.To(10),
+			expected:            framework.Wait,
+		},
+		{
+			name:                "cluster wide limit not reached",
+			clusterNotReadyPods: 9,
+			clusterWide:         ptr.To(10),
+			expected:            framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 0, false)
+			scheduler.args.MaxStartingPodsClusterWide = tc.clusterWide
+			scheduler.nodestate.(*NodeStateTest).clusterNotReadyPods = tc.clusterNotReadyPods
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+		})
+	}
+}
+
+func TestShouldConsiderStartingPodsOfNamespace(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		namespaceNotReadyPods int
+		perNamespace          *int
+		expected              framework.Code
+	}{
+		{
+			name:                  "no namespace limit",
+			namespaceNotReadyPods: 100,
+			expected:              framework.Success,
+		},
+		{
+			name:                  "namespace limit reached",
+			namespaceNotReadyPods: 5,
+			perNamespace:          ptr.To(5),
+			expected:              framework.Wait,
+		},
+		{
+			name:                  "namespace limit not reached",
+			namespaceNotReadyPods: 4,
+			perNamespace:          ptr.To(5),
+			expected:              framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 0, false)
+			scheduler.args.MaxStartingPodsPerNamespace = tc.perNamespace
+			scheduler.nodestate.(*NodeStateTest).namespaceNotReadyPods = map[string]int{"test-namespace": tc.namespaceNotReadyPods}
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+		})
+	}
+}
+
+func TestShouldDropWaitingPodsAfterDeadline(t *testing.T) {
+	scheduler := getTestingScheduler(0, 0, false)
+	scheduler.handle = getTestingHandle()
+	pod1 := getStartingPod("pod-1", "test-namespace", "uuid-1", true)
+	pod2 := getStartingPod("pod-2", "test-namespace", "uuid-2", true)
+
+	scheduler.waiting.Add(&pod1, "test-node", time.Now().Add(-time.Second))
+	scheduler.waiting.Add(&pod2, "test-node", time.Now().Add(time.Minute))
+
+	scheduler.releaseWaitingPods(nil, "test-node")
+
+	waiting := scheduler.waiting.List("test-node")
+	assert.Len(t, waiting, 1)
+	assert.Equal(t, types.UID("uuid-2"), waiting[0].Pod.UID)
+}
+
+func TestShouldReleaseReservationOnUnreserve(t *testing.T) {
+	scheduler := getTestingScheduler(0, 2, false)
+	state := &framework.CycleState{}
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	resp := scheduler.Reserve(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Success, resp.Code())
+
+	resp, _ = scheduler.Permit(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Success, resp.Code())
+	notReadyPods, err := scheduler.nodestate.NotReadyPods("test-node")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, notReadyPods)
+
+	scheduler.Unreserve(context.TODO(), state, &pod, "test-node")
+	notReadyPods, err = scheduler.nodestate.NotReadyPods("test-node")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, notReadyPods)
+}
+
+func TestShouldRemoveWaitingPodOnUnreserve(t *testing.T) {
+	scheduler := getTestingScheduler(0, 6, false)
+	state := &framework.CycleState{}
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Wait, resp.Code())
+
+	scheduler.Unreserve(context.TODO(), state, &pod, "test-node")
+	assert.Empty(t, scheduler.waiting.List("test-node"))
+}
+
 func getTestingScheduler(retryCounter int, notReadyPods int, limitPerCores bool) *ThunderingHerdScheduling {
 	var m sync.Mutex
 	counter := PodCounterTest{
 		counter: retryCounter,
 	}
-	nodeState := NodeStateTest{
+	nodeState := &NodeStateTest{
 		notReadyPods: notReadyPods,
 	}
 	args := &ThunderingHerdSchedulingArgs{}
@@ -209,37 +786,182 @@ func getTestingScheduler(retryCounter int, notReadyPods int, limitPerCores bool)
 		args.ParallelStartingPodsPerCore = nil
 	}
 	scheduler := &ThunderingHerdScheduling{
-		counter:   counter,
-		args:      args,
-		nodestate: nodeState,
-		mutex:     &m,
+		counter:       counter,
+		args:          args,
+		nodestate:     nodeState,
+		waiting:       waitingpods.New(),
+		recorder:      events.NewFakeRecorder(100),
+		mutex:         &m,
+		fallbackPods:  make(map[types.UID]struct{}),
+		failedRetries: make(map[types.UID]int),
 	}
 
 	return scheduler
 }
 
+func getTestingHandle(pods ...*v1.Pod) *HandleTest {
+	h := &HandleTest{
+		waitingPods: map[types.UID]*WaitingPodTest{},
+	}
+	for _, p := range pods {
+		h.waitingPods[p.UID] = &WaitingPodTest{pod: p}
+	}
+	return h
+}
+
+type HandleTest struct {
+	framework.Handle
+	waitingPods map[types.UID]*WaitingPodTest
+}
+
+func (h *HandleTest) GetWaitingPod(uid types.UID) framework.WaitingPod {
+	if w, ok := h.waitingPods[uid]; ok {
+		return w
+	}
+	return nil
+}
+
+type WaitingPodTest struct {
+	framework.WaitingPod
+	pod      *v1.Pod
+	allowed  bool
+	rejected bool
+}
+
+func (w *WaitingPodTest) GetPod() *v1.Pod {
+	return w.pod
+}
+
+func (w *WaitingPodTest) Allow(_ string) {
+	w.allowed = true
+}
+
+func (w *WaitingPodTest) Reject(_ string, _ string) {
+	w.rejected = true
+}
+
 type NodeStateTest struct {
+	node         *v1.Node
 	notReadyPods int
+	// not ready pods of an owner per node, the cluster wide count is the sum over all nodes
+	ownerNotReadyPods     map[string]map[string]int
+	namespaceNotReadyPods map[string]int
+	clusterNotReadyPods   int
+	startupCost           *float64
+	startingMilliCPU      int64
+	startupMilliCPU       int64
+	allocatableMilliCPU   int64
+	startingPods          []*v1.Pod
+	scheduledPods         []*v1.Pod
+	nodeNames             []string
+	exception             error
+}
+
+func (n *NodeStateTest) Node(nodeName string) (*v1.Node, error) {
+	if n.node == nil {
+		return nil, errors.New("node " + nodeName + " not found")
+	}
+	return n.node, nil
+}
+
+func (n *NodeStateTest) NotReadyPods(_ string) (int, error) {
+	return n.notReadyPods, n.exception
+}
+
+func (n *NodeStateTest) NotReadyPodsCost(_ string) (float64, error) {
+	return float64(n.notReadyPods), n.exception
+}
+
+func (n *NodeStateTest) StartupCost(_ *v1.Pod) float64 {
+	if n.startupCost != nil {
+		return *n.startupCost
+	}
+	return 1
+}
+
+func (n *NodeStateTest) NotReadyPodsMilliCPU(_ string) (int64, error) {
+	return n.startingMilliCPU, n.exception
+}
+
+func (n *NodeStateTest) StartupMilliCPU(_ *v1.Pod) int64 {
+	return n.startupMilliCPU
 }
 
-func (n NodeStateTest) NotReadyPods(_ string) int {
-	return n.notReadyPods
+func (n *NodeStateTest) MilliCPUAllowedInParallel(startupCPUFraction float64, _ string) (int64, error) {
+	return int64(float64(n.allocatableMilliCPU) * startupCPUFraction), nil
 }
 
-func (n NodeStateTest) AddSchedulingPod(_ *v1.Pod, _ string) {
+func (n *NodeStateTest) AddSchedulingPod(_ *v1.Pod, _ string) {
 	n.notReadyPods = n.notReadyPods + 1
 }
 
-func (n NodeStateTest) NotReadyPodsAllowedInParallel(podsPerNode *int, podsPerCore *float64, _ string) (int, error) {
+func (n *NodeStateTest) RemoveSchedulingPod(_ *v1.Pod, _ string) {
+	n.notReadyPods = n.notReadyPods - 1
+}
+
+func (n *NodeStateTest) BoundSchedulingPod(_ *v1.Pod, _ string) {
+}
+
+func (n *NodeStateTest) AddPodStartedHandler(_ nodestate.PodStartedHandler) {
+}
+
+func (n *NodeStateTest) AddPodDeletedHandler(_ nodestate.PodDeletedHandler) {
+}
+
+func (n *NodeStateTest) NotReadyPodsClusterWide(_ string) (int, error) {
+	return n.clusterNotReadyPods, n.exception
+}
+
+func (n *NodeStateTest) NotReadyNamespacePods(namespace string) (int, error) {
+	return n.namespaceNotReadyPods[namespace], n.exception
+}
+
+func (n *NodeStateTest) NotReadyOwnerPods(ownerKey string, nodeName string) (int, error) {
+	return n.ownerNotReadyPods[ownerKey][nodeName], n.exception
+}
+
+func (n *NodeStateTest) NotReadyOwnerPodsClusterWide(ownerKey string) (int, error) {
+	notReadyPods := 0
+	for _, val := range n.ownerNotReadyPods[ownerKey] {
+		notReadyPods += val
+	}
+	return notReadyPods, n.exception
+}
+
+func (n *NodeStateTest) IsPodStarting(pod *v1.Pod) bool {
+	if pod.Spec.NodeName == "" {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status != v1.ConditionTrue
+		}
+	}
+	return true
+}
+
+func (n *NodeStateTest) StartingPods(_ string) ([]*v1.Pod, []*v1.Pod, error) {
+	return n.startingPods, n.scheduledPods, n.exception
+}
+
+func (n *NodeStateTest) NodeNames() ([]string, error) {
+	return n.nodeNames, n.exception
+}
+
+func (n *NodeStateTest) NotReadyPodsAllowedInParallel(podsPerNode *int, podsPerCore *float64, _ string) (int, error) {
 	if podsPerNode != nil {
 		return *podsPerNode, nil
 	}
+	if podsPerCore == nil {
+		return nodestate.UnlimitedStartingPods, nil
+	}
 
 	return int(*podsPerCore), nil
 }
 
 type PodCounterTest struct {
 	counter   int
+	waited    time.Duration
 	exception error
 }
 
@@ -257,6 +979,15 @@ func (p PodCounterTest) SetCounter(_ *v1.Pod, val int) error {
 	return nil
 }
 
+func (p PodCounterTest) WaitedDuration(_ *v1.Pod) time.Duration {
+	return p.waited
+}
+
+func (p PodCounterTest) AddWaitedDuration(_ *v1.Pod, d time.Duration) (time.Duration, error) {
+	p.waited = p.waited + d
+	return p.waited, p.exception
+}
+
 func getStartingPod(name string, namespace string, uuid string, container bool) v1.Pod {
 	objMeta := meta_v1.ObjectMeta{
 		Name:      name,
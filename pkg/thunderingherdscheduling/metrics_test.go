@@ -0,0 +1,53 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	basemetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+func TestShouldRecordMetricsOfPermitDecisions(t *testing.T) {
+	metrics.Register()
+	waitDecisions := counterValue(t, metrics.PermitDecisions.WithLabelValues("Wait", "wait"))
+	forceAdmissions := counterValue(t, metrics.ForceAdmissions.WithLabelValues("maxRetries"))
+	waits := histogramCount(t, "thundering_herd_wait_duration_seconds")
+	retries := histogramCount(t, "thundering_herd_retries")
+
+	scheduler := getTestingScheduler(0, 6, false)
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	resp, _ := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "metrics-node")
+	assert.Equal(t, framework.Wait, resp.Code())
+
+	scheduler = getTestingScheduler(5, 6, false)
+	resp, _ = scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "metrics-node")
+	assert.Equal(t, framework.Success, resp.Code())
+
+	assert.Equal(t, waitDecisions+1, counterValue(t, metrics.PermitDecisions.WithLabelValues("Wait", "wait")))
+	assert.Equal(t, forceAdmissions+1, counterValue(t, metrics.ForceAdmissions.WithLabelValues("maxRetries")))
+	assert.Equal(t, waits+1, histogramCount(t, "thundering_herd_wait_duration_seconds"))
+	assert.Equal(t, retries+2, histogramCount(t, "thundering_herd_retries"))
+
+	startingPods, err := testutil.GetGaugeMetricValue(metrics.NodeStartingPods.WithLabelValues("metrics-node"))
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, startingPods)
+	allowedStartingPods, err := testutil.GetGaugeMetricValue(metrics.NodeAllowedStartingPods.WithLabelValues("metrics-node"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, allowedStartingPods)
+}
+
+func counterValue(t *testing.T, m basemetrics.CounterMetric) float64 {
+	val, err := testutil.GetCounterMetricValue(m)
+	assert.NoError(t, err)
+	return val
+}
+
+func histogramCount(t *testing.T, name string) uint64 {
+	vec, err := testutil.GetHistogramVecFromGatherer(legacyregistry.DefaultGatherer, name, nil)
+	assert.NoError(t, err)
+	return vec.GetAggregatedSampleCount()
+}
@@ -0,0 +1,54 @@
+package thunderingherdscheduling
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"time"
+)
+
+type Mode string
+
+const (
+	// ModeWait lets a pod wait at permit on the chosen node until a starting slot becomes free
+	ModeWait Mode = "wait"
+	// ModeReject rejects a pod at permit, it goes through scheduling again and can be placed onto a node with free startup budget
+	ModeReject Mode = "reject"
+	// ModeShadow admits every pod, the decisions which would have been taken are only logged, counted and recorded as events
+	ModeShadow Mode = "shadow"
+)
+
+func (m Mode) Validate() error {
+	switch m {
+	case ModeWait, ModeReject, ModeShadow:
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %s", m)
+	}
+}
+
+func (t *ThunderingHerdScheduling) mode() Mode {
+	if t.args.Mode == nil {
+		return ModeWait
+	}
+	return Mode(*t.args.Mode)
+}
+
+// incrementCounter increments the retry counter of the pod, in shadow mode the pod isn't patched
+func (t *ThunderingHerdScheduling) incrementCounter(p *v1.Pod) (int, error) {
+	if t.mode() == ModeShadow {
+		return t.counter.CurrentCounter(p) + 1, nil
+	}
+	return t.counter.IncrementCounter(p)
+}
+
+// recordShadowDecision logs the decision which would have been taken for a pod which is admitted in shadow mode
+func (t *ThunderingHerdScheduling) recordShadowDecision(p *v1.Pod, nodeName string, status *framework.Status, waitTime time.Duration) {
+	klog.InfoS("Pod is admitted in shadow mode",
+		"pod", klog.KObj(p),
+		"nodeName", nodeName,
+		"result", status.Code().String(),
+		"reason", status.Message(),
+		"waitTime", waitTime)
+}
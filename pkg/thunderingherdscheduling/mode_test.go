@@ -0,0 +1,82 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+	"time"
+)
+
+func TestShouldAdmitAndRecordDecisionInShadowMode(t *testing.T) {
+	testcases := []struct {
+		name           string
+		notReadyPods   int
+		failurePolicy  *string
+		nodeStateFails bool
+		expectedEvent  string
+	}{
+		{
+			name:         "free startup budget",
+			notReadyPods: 2,
+		},
+		{
+			name:          "no free startup budget",
+			notReadyPods:  6,
+			expectedEvent: "Normal StartupThrottled Shadow mode, not applied: Waiting 50s for a starting slot on node test-node, 6 pods are not ready and 3 are allowed to start in parallel: node has no free startup budget",
+		},
+		{
+			name:           "lookup fails closed",
+			notReadyPods:   6,
+			failurePolicy:  ptr.To("failClosed"),
+			nodeStateFails: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(1, tc.notReadyPods, false)
+			scheduler.args.Mode = ptr.To("shadow")
+			scheduler.args.FailurePolicy = tc.failurePolicy
+			// the counter must not be patched in shadow mode
+			scheduler.counter = PodCounterTest{counter: 1, exception: errors.New("patch failed")}
+			if tc.nodeStateFails {
+				scheduler.nodestate.(*NodeStateTest).exception = errors.New("lookup failed")
+			}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			resp, waitTime := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+			assert.Equal(t, framework.Success, resp.Code())
+			assert.Equal(t, time.Duration(0), waitTime)
+			assert.Empty(t, scheduler.waiting.List("test-node"))
+			assert.Equal(t, tc.notReadyPods+1, scheduler.nodestate.(*NodeStateTest).notReadyPods)
+
+			recorder := scheduler.recorder.(*events.FakeRecorder)
+			if tc.expectedEvent == "" {
+				assert.Empty(t, recorder.Events)
+			} else {
+				assert.Equal(t, tc.expectedEvent, <-recorder.Events)
+			}
+		})
+	}
+}
+
+func TestShouldNotFilterScoreOrGateInShadowMode(t *testing.T) {
+	scheduler := getTestingScheduler(0, 6, false)
+	scheduler.args.Mode = ptr.To("shadow")
+	scheduler.args.MaxStartingPodsClusterWide = ptr.To(1)
+	scheduler.nodestate.(*NodeStateTest).clusterNotReadyPods = 6
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	assert.True(t, scheduler.Filter(context.TODO(), &framework.CycleState{}, &pod, getTestingNodeInfo("test-node")).IsSuccess())
+	assert.True(t, scheduler.PreEnqueue(context.TODO(), &pod).IsSuccess())
+
+	// a node with free startup budget isn't preferred
+	scheduler.nodestate.(*NodeStateTest).notReadyPods = 0
+	score, status := scheduler.Score(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+	assert.True(t, status.IsSuccess())
+	assert.Equal(t, int64(0), score)
+}
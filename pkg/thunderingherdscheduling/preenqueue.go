@@ -0,0 +1,36 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+var _ framework.PreEnqueuePlugin = &ThunderingHerdScheduling{}
+
+// PreEnqueue keeps pods out of the scheduling queue while the cluster wide, namespace or owner budget is exhausted,
+// the pods are checked again on the events registered in EventsToRegister, which don't include starting pods becoming ready
+func (t *ThunderingHerdScheduling) PreEnqueue(_ context.Context, p *v1.Pod) *framework.Status {
+	if t.mode() == ModeShadow || !t.limitsClusterWide() {
+		return nil
+	}
+
+	reason, err := t.exemptionReason(p)
+	if err != nil {
+		return t.onFilterFailure(p, "", sourceNamespaces, err)
+	}
+	if reason != "" {
+		return nil
+	}
+
+	d, err := t.decideClusterWide(p)
+	if err != nil {
+		return t.onFilterFailure(p, "", sourceNodeState, err)
+	}
+	if !d.admitted() {
+		klog.V(4).InfoS("Pod is not enqueued as there are already more pods not ready then allowed to start parallel", "pod", klog.KObj(p), "reason", d.reason())
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, d.reason())
+	}
+	return nil
+}
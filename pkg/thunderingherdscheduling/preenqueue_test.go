@@ -0,0 +1,98 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+)
+
+func TestShouldGatePodsWhileClusterWideBudgetIsExhausted(t *testing.T) {
+	testcases := []struct {
+		name                string
+		clusterWide         *int
+		perNamespace        *int
+		perOwnerClusterWide *int
+		annotations         map[string]string
+		failurePolicy       *string
+		nodeStateFails      bool
+		expected            framework.Code
+		expectedReason      string
+	}{
+		{
+			name:     "no cluster wide budget",
+			expected: framework.Success,
+		},
+		{
+			name:        "cluster wide budget is free",
+			clusterWide: ptr.To(11),
+			expected:    framework.Success,
+		},
+		{
+			name:           "cluster wide budget is exhausted",
+			clusterWide:    ptr.To(10),
+			expected:       framework.UnschedulableAndUnresolvable,
+			expectedReason: "too many pods are starting in the cluster",
+		},
+		{
+			name:           "namespace budget is exhausted",
+			perNamespace:   ptr.To(5),
+			expected:       framework.UnschedulableAndUnresolvable,
+			expectedReason: "too many pods of the namespace are starting",
+		},
+		{
+			name:                "owner budget is exhausted",
+			perOwnerClusterWide: ptr.To(2),
+			expected:            framework.UnschedulableAndUnresolvable,
+			expectedReason:      "too many pods of the owner are starting",
+		},
+		{
+			name:        "pod is not throttled",
+			clusterWide: ptr.To(10),
+			annotations: map[string]string{SkipAnnotation: "true"},
+			expected:    framework.Success,
+		},
+		{
+			name:           "lookup fails open",
+			clusterWide:    ptr.To(10),
+			nodeStateFails: true,
+			expected:       framework.Success,
+		},
+		{
+			name:           "lookup fails closed",
+			clusterWide:    ptr.To(10),
+			failurePolicy:  ptr.To("failClosed"),
+			nodeStateFails: true,
+			expected:       framework.Error,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 0, false)
+			scheduler.args.MaxStartingPodsClusterWide = tc.clusterWide
+			scheduler.args.MaxStartingPodsPerNamespace = tc.perNamespace
+			scheduler.args.MaxStartingPodsPerOwnerClusterWide = tc.perOwnerClusterWide
+			scheduler.args.FailurePolicy = tc.failurePolicy
+			nodeState := scheduler.nodestate.(*NodeStateTest)
+			nodeState.clusterNotReadyPods = 10
+			nodeState.namespaceNotReadyPods = map[string]int{"test-namespace": 5}
+			nodeState.ownerNotReadyPods = map[string]map[string]int{"test-namespace/ReplicaSet/test-rs": {"node-1": 1, "node-2": 1}}
+			if tc.nodeStateFails {
+				nodeState.exception = errors.New("lookup failed")
+			}
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+			pod.Annotations = tc.annotations
+			pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "test-rs", Controller: ptr.To(true)}}
+
+			status := scheduler.PreEnqueue(context.TODO(), &pod)
+			assert.Equal(t, tc.expected, status.Code())
+			if tc.expectedReason != "" {
+				assert.Equal(t, []string{tc.expectedReason}, status.Reasons())
+			}
+		})
+	}
+}
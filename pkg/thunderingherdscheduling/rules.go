@@ -0,0 +1,77 @@
+package thunderingherdscheduling
+
+import (
+	"errors"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// NodeRule overrides the arguments for all nodes matching the node selector
+type NodeRule struct {
+	NodeSelector                *meta_v1.LabelSelector `json:"nodeSelector"`
+	ParallelStartingPodsPerNode *int                   `json:"parallelStartingPodsPerNode"`
+	ParallelStartingPodsPerCore *float64               `json:"parallelStartingPodsPerCore"`
+	TimeoutSeconds              *int                   `json:"timeoutSeconds"`
+	MaxRetries                  *int                   `json:"maxRetries"`
+}
+
+func (r NodeRule) validate() error {
+	// a nil selector matches no node, all nodes are matched by an empty selector
+	if r.NodeSelector == nil {
+		return errors.New("nodeSelector must be set, use {} to match all nodes")
+	}
+	if r.ParallelStartingPodsPerCore != nil && r.ParallelStartingPodsPerNode != nil {
+		return fmt.Errorf("cannot specify parallelStartingPodsPerNode and parallelStartingPodsPerCore at the same time")
+	}
+	_, err := meta_v1.LabelSelectorAsSelector(r.NodeSelector)
+	return err
+}
+
+func (r NodeRule) matches(node *v1.Node) bool {
+	selector, err := meta_v1.LabelSelectorAsSelector(r.NodeSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(node.Labels))
+}
+
+// ForNode returns the arguments of the first rule matching the node, unset values of the rule fall back to the top level arguments
+func (in *ThunderingHerdSchedulingArgs) ForNode(node *v1.Node) *ThunderingHerdSchedulingArgs {
+	for i, rule := range in.Rules {
+		if !rule.matches(node) {
+			continue
+		}
+
+		klog.V(4).InfoS("Node matches rule", "nodeName", node.Name, "rule", i)
+		out := in.DeepCopy()
+		if rule.ParallelStartingPodsPerNode != nil || rule.ParallelStartingPodsPerCore != nil {
+			out.ParallelStartingPodsPerNode = rule.ParallelStartingPodsPerNode
+			out.ParallelStartingPodsPerCore = rule.ParallelStartingPodsPerCore
+		}
+		if rule.TimeoutSeconds != nil {
+			out.TimeoutSeconds = rule.TimeoutSeconds
+		}
+		if rule.MaxRetries != nil {
+			out.MaxRetries = rule.MaxRetries
+		}
+		return out
+	}
+
+	return in
+}
+
+// nodeArgs returns the arguments for the node
+func (t *ThunderingHerdScheduling) nodeArgs(nodeName string) (*ThunderingHerdSchedulingArgs, error) {
+	if len(t.args.Rules) == 0 {
+		return t.args, nil
+	}
+
+	node, err := t.nodestate.Node(nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup node %s for rules: %v", nodeName, err)
+	}
+	return t.args.ForNode(node), nil
+}
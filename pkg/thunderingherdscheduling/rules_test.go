@@ -0,0 +1,156 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+	"time"
+)
+
+func TestArgsForNode(t *testing.T) {
+	args := &ThunderingHerdSchedulingArgs{
+		ParallelStartingPodsPerNode: ptr.To(3),
+		TimeoutSeconds:              ptr.To(5),
+		MaxRetries:                  ptr.To(5),
+		Rules: []NodeRule{
+			{
+				NodeSelector:                &meta_v1.LabelSelector{MatchLabels: map[string]string{"pool": "spot"}},
+				ParallelStartingPodsPerCore: ptr.To(0.5),
+				MaxRetries:                  ptr.To(2),
+			},
+			{
+				NodeSelector: &meta_v1.LabelSelector{MatchExpressions: []meta_v1.LabelSelectorRequirement{
+					{Key: "pool", Operator: meta_v1.LabelSelectorOpIn, Values: []string{"spot", "high-cpu"}},
+				}},
+				ParallelStartingPodsPerNode: ptr.To(10),
+				TimeoutSeconds:              ptr.To(2),
+			},
+		},
+	}
+
+	testcases := []struct {
+		name        string
+		labels      map[string]string
+		perNode     *int
+		perCore     *float64
+		timeout     int
+		maxRetries  int
+		sameAsInput bool
+	}{
+		{
+			name:        "no rule matches",
+			labels:      map[string]string{"pool": "on-demand"},
+			perNode:     ptr.To(3),
+			timeout:     5,
+			maxRetries:  5,
+			sameAsInput: true,
+		},
+		{
+			name:       "first matching rule wins",
+			labels:     map[string]string{"pool": "spot"},
+			perCore:    ptr.To(0.5),
+			timeout:    5,
+			maxRetries: 2,
+		},
+		{
+			name:       "second rule",
+			labels:     map[string]string{"pool": "high-cpu"},
+			perNode:    ptr.To(10),
+			timeout:    2,
+			maxRetries: 5,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "node-1", Labels: tc.labels}}
+			out := args.ForNode(node)
+
+			assert.Equal(t, tc.perNode, out.ParallelStartingPodsPerNode)
+			assert.Equal(t, tc.perCore, out.ParallelStartingPodsPerCore)
+			assert.Equal(t, tc.timeout, *out.TimeoutSeconds)
+			assert.Equal(t, tc.maxRetries, *out.MaxRetries)
+			assert.Equal(t, tc.sameAsInput, out == args)
+		})
+	}
+	assert.Equal(t, ptr.To(3), args.ParallelStartingPodsPerNode)
+}
+
+func TestParseArgumentsWithRules(t *testing.T) {
+	testcases := []struct {
+		name        string
+		input       string
+		errExpected bool
+		errMsg      string
+	}{
+		{
+			name:  "valid",
+			input: `{"rules": [{"nodeSelector": {"matchLabels": {"pool": "spot"}}, "parallelStartingPodsPerNode": 1, "timeoutSeconds": 3, "maxRetries": 2}]}`,
+		},
+		{
+			name:        "both parallel settings",
+			input:       `{"rules": [{"nodeSelector": {}, "parallelStartingPodsPerNode": 1, "parallelStartingPodsPerCore": 0.5}]}`,
+			errExpected: true,
+			errMsg:      "invalid rule 0: cannot specify parallelStartingPodsPerNode and parallelStartingPodsPerCore at the same time",
+		},
+		{
+			name:        "missing selector",
+			input:       `{"rules": [{"parallelStartingPodsPerNode": 1}]}`,
+			errExpected: true,
+			errMsg:      "invalid rule 0: nodeSelector must be set, use {} to match all nodes",
+		},
+		{
+			name:  "empty selector",
+			input: `{"rules": [{"nodeSelector": {}, "parallelStartingPodsPerNode": 1}]}`,
+		},
+		{
+			name:        "invalid selector",
+			input:       `{"rules": [{"nodeSelector": {"matchExpressions": [{"key": "pool", "operator": "Near"}]}}]}`,
+			errExpected: true,
+			errMsg:      "invalid rule 0",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			unk := runtime.Unknown{Raw: []byte(tc.input)}
+			_, err := ParseArguments(&unk)
+			if tc.errExpected {
+				assert.ErrorContains(t, err, tc.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestShouldApplyMatchingRuleOnPermit(t *testing.T) {
+	scheduler := getTestingScheduler(0, 4, false)
+	scheduler.args.Rules = []NodeRule{
+		{
+			NodeSelector:                &meta_v1.LabelSelector{MatchLabels: map[string]string{"pool": "high-cpu"}},
+			ParallelStartingPodsPerNode: ptr.To(8),
+		},
+		{
+			NodeSelector:   &meta_v1.LabelSelector{MatchLabels: map[string]string{"pool": "spot"}},
+			TimeoutSeconds: ptr.To(2),
+		},
+	}
+	nodeState := scheduler.nodestate.(*NodeStateTest)
+	state := &framework.CycleState{}
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+	nodeState.node = &v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "test-node", Labels: map[string]string{"pool": "high-cpu"}}}
+	resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Success, resp.Code())
+
+	nodeState.node = &v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "test-node", Labels: map[string]string{"pool": "spot"}}}
+	resp, waitTime := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+	assert.Equal(t, framework.Wait, resp.Code())
+	assert.Equal(t, 4*time.Second, waitTime)
+}
@@ -0,0 +1,55 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+var _ framework.ScorePlugin = &ThunderingHerdScheduling{}
+
+// Score prefers nodes with a large free share of their startup budget, so pods are placed where they can start right away
+func (t *ThunderingHerdScheduling) Score(_ context.Context, _ *framework.CycleState, p *v1.Pod, nodeName string) (int64, *framework.Status) {
+	// every node gets the same score, the placement must not change in shadow mode
+	if t.mode() == ModeShadow {
+		return 0, nil
+	}
+
+	args, err := t.nodeArgs(nodeName)
+	if err != nil {
+		return t.onScoreFailure(p, nodeName, sourceNodeState, err)
+	}
+	budget, err := t.nodeStartupBudget(nodeName, args)
+	if err != nil {
+		return t.onScoreFailure(p, nodeName, sourceNodeState, err)
+	}
+
+	score := int64(budget.free() * float64(framework.MaxNodeScore))
+	klog.V(5).InfoS("Scored node by its free startup budget", "pod", klog.KObj(p), "nodeName", nodeName, "score", score)
+	return score, nil
+}
+
+// ScoreExtensions returns nil as the scores are already within the range of node scores
+func (t *ThunderingHerdScheduling) ScoreExtensions() framework.ScoreExtensions {
+	return nil
+}
+
+// free returns the share of the startup budget which is not used by starting pods, between 0 and 1
+func (b startupBudget) free() float64 {
+	free := 1.0
+	if b.limitsPods() {
+		free = freeShare(b.startingCost, float64(b.maxAllowedStartingPods))
+	}
+	if b.maxAllowedStartingMilliCPU != nil {
+		free = min(free, freeShare(float64(b.startingMilliCPU), float64(*b.maxAllowedStartingMilliCPU)))
+	}
+	return free
+}
+
+func freeShare(used float64, budget float64) float64 {
+	if budget <= 0 {
+		return 0
+	}
+	return max(0, 1-used/budget)
+}
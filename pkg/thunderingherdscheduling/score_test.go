@@ -0,0 +1,94 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+)
+
+func TestShouldScoreNodesByFreeStartupBudget(t *testing.T) {
+	testcases := []struct {
+		name               string
+		notReadyPods       int
+		startupCPUFraction *float64
+		startingMilliCPU   int64
+		expected           int64
+	}{
+		{
+			name:         "nothing is starting",
+			notReadyPods: 0,
+			expected:     100,
+		},
+		{
+			name:         "one of three slots is used",
+			notReadyPods: 1,
+			expected:     66,
+		},
+		{
+			name:         "all slots are used",
+			notReadyPods: 3,
+			expected:     0,
+		},
+		{
+			name:         "more pods than slots are starting",
+			notReadyPods: 6,
+			expected:     0,
+		},
+		{
+			name:               "cpu budget is used more than the slots",
+			notReadyPods:       1,
+			startupCPUFraction: ptr.To(0.5),
+			startingMilliCPU:   1500,
+			expected:           25,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, tc.notReadyPods, false)
+			scheduler.args.StartupCPUFraction = tc.startupCPUFraction
+			nodeState := scheduler.nodestate.(*NodeStateTest)
+			nodeState.allocatableMilliCPU = 4000
+			nodeState.startingMilliCPU = tc.startingMilliCPU
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			score, status := scheduler.Score(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+			assert.True(t, status.IsSuccess())
+			assert.Equal(t, tc.expected, score)
+		})
+	}
+}
+
+func TestShouldApplyFailurePolicyOnScore(t *testing.T) {
+	testcases := []struct {
+		name          string
+		failurePolicy *string
+		expected      framework.Code
+	}{
+		{
+			name:     "fail open",
+			expected: framework.Success,
+		},
+		{
+			name:          "fail closed",
+			failurePolicy: ptr.To("failClosed"),
+			expected:      framework.Error,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 0, false)
+			scheduler.args.FailurePolicy = tc.failurePolicy
+			scheduler.nodestate.(*NodeStateTest).exception = errors.New("lookup failed")
+			pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+
+			score, status := scheduler.Score(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+			assert.Equal(t, tc.expected, status.Code())
+			assert.Equal(t, int64(0), score)
+		})
+	}
+}
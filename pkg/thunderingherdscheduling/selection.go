@@ -0,0 +1,53 @@
+package thunderingherdscheduling
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+)
+
+const (
+	// SkipAnnotation opts a single pod out of the throttling when set to "true"
+	SkipAnnotation = "thundering-herd/skip"
+)
+
+// exemptionReason returns why the pod is scheduled without throttling, an empty reason means the pod is throttled
+func (t *ThunderingHerdScheduling) exemptionReason(p *v1.Pod) (string, error) {
+	if t.args.PriorityThreshold != nil && corev1helpers.PodPriority(p) >= *t.args.PriorityThreshold {
+		return "priority is above the priority threshold", nil
+	}
+
+	if p.Annotations[SkipAnnotation] == "true" {
+		return "pod opted out by annotation", nil
+	}
+
+	if !selectorMatches(t.args.PodSelector, p.Labels) {
+		return "pod does not match the pod selector", nil
+	}
+
+	if t.args.NamespaceSelector != nil {
+		ns, err := t.namespaces.Get(p.Namespace)
+		if err != nil {
+			return "", fmt.Errorf("failed to lookup namespace %s for namespace selector: %v", p.Namespace, err)
+		}
+		if !selectorMatches(t.args.NamespaceSelector, ns.Labels) {
+			return "namespace does not match the namespace selector", nil
+		}
+	}
+
+	return "", nil
+}
+
+// selectorMatches returns true for a nil selector
+func selectorMatches(selector *meta_v1.LabelSelector, l map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	s, err := meta_v1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return true
+	}
+	return s.Matches(labels.Set(l))
+}
@@ -0,0 +1,141 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+func TestShouldOnlyThrottleSelectedPods(t *testing.T) {
+	testcases := []struct {
+		name              string
+		namespace         string
+		labels            map[string]string
+		annotations       map[string]string
+		namespaceSelector *meta_v1.LabelSelector
+		podSelector       *meta_v1.LabelSelector
+		expected          framework.Code
+	}{
+		{
+			name:      "without selectors",
+			namespace: "apps",
+			expected:  framework.Wait,
+		},
+		{
+			name:        "opted out by annotation",
+			namespace:   "apps",
+			annotations: map[string]string{SkipAnnotation: "true"},
+			expected:    framework.Success,
+		},
+		{
+			name:        "annotation not true",
+			namespace:   "apps",
+			annotations: map[string]string{SkipAnnotation: "false"},
+			expected:    framework.Wait,
+		},
+		{
+			name:              "namespace matches",
+			namespace:         "apps",
+			namespaceSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"throttle": "true"}},
+			expected:          framework.Wait,
+		},
+		{
+			name:              "namespace does not match",
+			namespace:         "infra",
+			namespaceSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"throttle": "true"}},
+			expected:          framework.Success,
+		},
+		{
+			name:              "unknown namespace fails open",
+			namespace:         "unknown",
+			namespaceSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"throttle": "true"}},
+			expected:          framework.Success,
+		},
+		{
+			name:        "pod matches",
+			namespace:   "apps",
+			labels:      map[string]string{"tier": "backend"},
+			podSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"tier": "backend"}},
+			expected:    framework.Wait,
+		},
+		{
+			name:      "pod does not match",
+			namespace: "apps",
+			labels:    map[string]string{"tier": "frontend"},
+			podSelector: &meta_v1.LabelSelector{MatchExpressions: []meta_v1.LabelSelectorRequirement{
+				{Key: "tier", Operator: meta_v1.LabelSelectorOpNotIn, Values: []string{"frontend"}},
+			}},
+			expected: framework.Success,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := getTestingScheduler(0, 6, false)
+			scheduler.args.NamespaceSelector = tc.namespaceSelector
+			scheduler.args.PodSelector = tc.podSelector
+			scheduler.namespaces = getTestingNamespaceLister(t,
+				&v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: "apps", Labels: map[string]string{"throttle": "true"}}},
+				&v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: "infra"}},
+			)
+			state := &framework.CycleState{}
+			pod := getStartingPod("test-pod", tc.namespace, "uuid", true)
+			pod.Labels = tc.labels
+			pod.Annotations = tc.annotations
+
+			resp, _ := scheduler.Permit(context.TODO(), state, &pod, "test-node")
+			assert.Equal(t, tc.expected, resp.Code())
+		})
+	}
+}
+
+func TestParseArgumentsWithSelectors(t *testing.T) {
+	testcases := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name:  "valid",
+			input: `{"namespaceSelector": {"matchLabels": {"throttle": "true"}}, "podSelector": {"matchExpressions": [{"key": "tier", "operator": "Exists"}]}}`,
+		},
+		{
+			name:   "invalid namespace selector",
+			input:  `{"namespaceSelector": {"matchExpressions": [{"key": "throttle", "operator": "Near"}]}}`,
+			errMsg: "invalid namespaceSelector",
+		},
+		{
+			name:   "invalid pod selector",
+			input:  `{"podSelector": {"matchExpressions": [{"key": "tier", "operator": "Near"}]}}`,
+			errMsg: "invalid podSelector",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			unk := runtime.Unknown{Raw: []byte(tc.input)}
+			_, err := ParseArguments(&unk)
+			if tc.errMsg != "" {
+				assert.ErrorContains(t, err, tc.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func getTestingNamespaceLister(t *testing.T, namespaces ...*v1.Namespace) corelisters.NamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range namespaces {
+		if err := indexer.Add(ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return corelisters.NewNamespaceLister(indexer)
+}
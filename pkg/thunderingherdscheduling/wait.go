@@ -0,0 +1,59 @@
+package thunderingherdscheduling
+
+import (
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/waitingpods"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxTotalWaitAnnotation overrides how many seconds a pod may wait in total before it's scheduled anyway
+	MaxTotalWaitAnnotation = "thundering-herd/max-total-wait-seconds"
+)
+
+// maxTotalWait returns how long the pod may wait in total, the annotation of the pod takes precedence over the arguments
+func (t *ThunderingHerdScheduling) maxTotalWait(p *v1.Pod) (time.Duration, bool) {
+	if strVal, exists := p.Annotations[MaxTotalWaitAnnotation]; exists {
+		val, err := strconv.Atoi(strVal)
+		if err == nil && val >= 0 {
+			return time.Duration(val) * time.Second, true
+		}
+		klog.ErrorS(err, "Failed to parse annotation", "annotation", MaxTotalWaitAnnotation, "value", strVal, "pod", klog.KObj(p))
+	}
+
+	if t.args.MaxTotalWaitSeconds == nil {
+		return 0, false
+	}
+	return time.Duration(*t.args.MaxTotalWaitSeconds) * time.Second, true
+}
+
+// limitTotalWait shortens the wait to the remaining total wait of the pod,
+// false is returned as soon as the pod waited for the max total wait already
+func (t *ThunderingHerdScheduling) limitTotalWait(p *v1.Pod, waitTime time.Duration) (time.Duration, bool) {
+	maxTotalWait, ok := t.maxTotalWait(p)
+	if !ok {
+		return waitTime, true
+	}
+
+	remaining := maxTotalWait - t.counter.WaitedDuration(p)
+	if remaining <= 0 {
+		return 0, false
+	}
+	return min(waitTime, remaining), true
+}
+
+// recordWaited adds the time the pod actually waited at permit to its waited duration once the wait ended,
+// the pod is patched in the background as waits end in the event handlers of the informer
+func (t *ThunderingHerdScheduling) recordWaited(w waitingpods.WaitingPod) {
+	if _, ok := t.maxTotalWait(w.Pod); !ok {
+		return
+	}
+	waited := w.Waited()
+	go func() {
+		if _, err := t.counter.AddWaitedDuration(w.Pod, waited); err != nil {
+			klog.ErrorS(err, "Failed to record waited duration", "pod", klog.KObj(w.Pod), "waited", waited)
+		}
+	}()
+}
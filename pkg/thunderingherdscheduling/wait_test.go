@@ -0,0 +1,79 @@
+package thunderingherdscheduling
+
+import (
+	"context"
+	"github.com/dbschenker/thundering-herd-scheduler/pkg/podcounter"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/ptr"
+	"testing"
+	"time"
+)
+
+func TestShouldRecordActuallyWaitedDurationOnRelease(t *testing.T) {
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	client := testclient.NewSimpleClientset(&pod)
+	scheduler := getTestingScheduler(0, 6, false)
+	scheduler.counter = podcounter.New(client)
+	scheduler.args.MaxTotalWaitSeconds = ptr.To(300)
+
+	resp, waitTime := scheduler.Permit(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+	assert.Equal(t, framework.Wait, resp.Code())
+	assert.Equal(t, 25*time.Second, waitTime)
+	// the planned wait is not recorded
+	assert.NotContains(t, getPod(t, client, pod).Annotations, podcounter.WaitedAnnotation)
+
+	scheduler.nodestate.(*NodeStateTest).notReadyPods = 1
+	handle := getTestingHandle(&pod)
+	scheduler.handle = handle
+	scheduler.releaseWaitingPods(nil, "test-node")
+	assert.True(t, handle.waitingPods["uuid"].allowed)
+
+	assert.Eventually(t, func() bool {
+		return getPod(t, client, pod).Annotations[podcounter.WaitedAnnotation] != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	waited, err := time.ParseDuration(getPod(t, client, pod).Annotations[podcounter.WaitedAnnotation])
+	assert.NoError(t, err)
+	assert.Greater(t, waited, time.Duration(0))
+	assert.Less(t, waited, time.Second)
+}
+
+func TestShouldRecordWaitedDurationUpToDeadlineOnTimeout(t *testing.T) {
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	client := testclient.NewSimpleClientset(&pod)
+	scheduler := getTestingScheduler(0, 6, false)
+	scheduler.counter = podcounter.New(client)
+	scheduler.args.MaxTotalWaitSeconds = ptr.To(300)
+
+	scheduler.waiting.Add(&pod, "test-node", time.Now().Add(10*time.Millisecond))
+	w := scheduler.waiting.List("test-node")[0]
+	time.Sleep(20 * time.Millisecond)
+
+	scheduler.Unreserve(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+
+	assert.Empty(t, scheduler.waiting.List("test-node"))
+	assert.Eventually(t, func() bool {
+		return getPod(t, client, pod).Annotations[podcounter.WaitedAnnotation] == w.Deadline.Sub(w.Since).String()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestShouldNotRecordWaitedDurationWithoutMaxTotalWait(t *testing.T) {
+	pod := getStartingPod("test-pod", "test-namespace", "uuid", true)
+	client := testclient.NewSimpleClientset(&pod)
+	scheduler := getTestingScheduler(0, 6, false)
+	scheduler.counter = podcounter.New(client)
+
+	scheduler.waiting.Add(&pod, "test-node", time.Now())
+	scheduler.Unreserve(context.TODO(), &framework.CycleState{}, &pod, "test-node")
+
+	assert.NotContains(t, getPod(t, client, pod).Annotations, podcounter.WaitedAnnotation)
+}
+
+func getPod(t *testing.T, client *testclient.Clientset, pod v1.Pod) *v1.Pod {
+	ret, err := client.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	return ret
+}
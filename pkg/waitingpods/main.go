@@ -0,0 +1,121 @@
+package waitingpods
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"sync"
+	"time"
+)
+
+// WaitingPod is a pod which was moved into waiting state by the permit plugin
+type WaitingPod struct {
+	Pod      *v1.Pod
+	NodeName string
+	Since    time.Time
+	Deadline time.Time
+}
+
+// Waited returns how long the pod waited until now, at most until its deadline
+func (w WaitingPod) Waited() time.Duration {
+	return min(time.Since(w.Since), w.Deadline.Sub(w.Since))
+}
+
+type WaitingPodsInterface interface {
+	Add(pod *v1.Pod, nodeName string, deadline time.Time)
+	Remove(uid types.UID) (WaitingPod, bool)
+	List(nodeName string) []WaitingPod
+	Nodes() []string
+}
+
+// Queue keeps the waiting pods per node ordered by priority, pods with the same priority in the order they started waiting
+type Queue struct {
+	pods  map[string][]WaitingPod
+	nodes map[types.UID]string
+	lock  *sync.Mutex
+}
+
+func New() WaitingPodsInterface {
+	var lock sync.Mutex
+	return &Queue{
+		pods:  make(map[string][]WaitingPod),
+		nodes: make(map[types.UID]string),
+		lock:  &lock,
+	}
+}
+
+func (q *Queue) Add(pod *v1.Pod, nodeName string, deadline time.Time) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// a pod can only wait on a single node, therefore a previous entry is replaced
+	q.remove(pod.UID)
+
+	pods := q.pods[nodeName]
+	priority := corev1helpers.PodPriority(pod)
+	i := len(pods)
+	for i > 0 && corev1helpers.PodPriority(pods[i-1].Pod) < priority {
+		i--
+	}
+
+	pods = append(pods, WaitingPod{})
+	copy(pods[i+1:], pods[i:])
+	pods[i] = WaitingPod{
+		Pod:      pod,
+		NodeName: nodeName,
+		Since:    time.Now(),
+		Deadline: deadline,
+	}
+	q.pods[nodeName] = pods
+	q.nodes[pod.UID] = nodeName
+}
+
+// Remove returns the removed pod, false if it wasn't waiting
+func (q *Queue) Remove(uid types.UID) (WaitingPod, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.remove(uid)
+}
+
+func (q *Queue) List(nodeName string) []WaitingPod {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	ret := make([]WaitingPod, len(q.pods[nodeName]))
+	copy(ret, q.pods[nodeName])
+	return ret
+}
+
+// Nodes returns the nodes with waiting pods
+func (q *Queue) Nodes() []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	ret := make([]string, 0, len(q.pods))
+	for nodeName := range q.pods {
+		ret = append(ret, nodeName)
+	}
+	return ret
+}
+
+func (q *Queue) remove(uid types.UID) (WaitingPod, bool) {
+	nodeName, ok := q.nodes[uid]
+	if !ok {
+		return WaitingPod{}, false
+	}
+	delete(q.nodes, uid)
+
+	var removed WaitingPod
+	for i, w := range q.pods[nodeName] {
+		if w.Pod.UID == uid {
+			removed = w
+			q.pods[nodeName] = append(q.pods[nodeName][:i], q.pods[nodeName][i+1:]...)
+			break
+		}
+	}
+	if len(q.pods[nodeName]) == 0 {
+		delete(q.pods, nodeName)
+	}
+	return removed, true
+}
@@ -0,0 +1,104 @@
+package waitingpods
+
+import (
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"testing"
+	"time"
+)
+
+func TestShouldListWaitingPodsInOrderOfArrival(t *testing.T) {
+	q := New()
+	deadline := time.Now()
+
+	q.Add(getWaitingTestPod("pod-1", "uid-1"), "node-1", deadline)
+	q.Add(getWaitingTestPod("pod-2", "uid-2"), "node-1", deadline)
+	q.Add(getWaitingTestPod("pod-3", "uid-3"), "node-2", deadline)
+
+	assert.Equal(t, []types.UID{"uid-1", "uid-2"}, uids(q.List("node-1")))
+	assert.Equal(t, []types.UID{"uid-3"}, uids(q.List("node-2")))
+	assert.Empty(t, q.List("node-3"))
+}
+
+func TestShouldListWaitingPodsInOrderOfPriority(t *testing.T) {
+	q := New()
+	deadline := time.Now()
+
+	q.Add(getWaitingTestPod("pod-1", "uid-1"), "node-1", deadline)
+	q.Add(withPriority(getWaitingTestPod("pod-2", "uid-2"), 100), "node-1", deadline)
+	q.Add(withPriority(getWaitingTestPod("pod-3", "uid-3"), 1000), "node-1", deadline)
+	q.Add(withPriority(getWaitingTestPod("pod-4", "uid-4"), 100), "node-1", deadline)
+	q.Add(getWaitingTestPod("pod-5", "uid-5"), "node-1", deadline)
+
+	assert.Equal(t, []types.UID{"uid-3", "uid-2", "uid-4", "uid-1", "uid-5"}, uids(q.List("node-1")))
+}
+
+func TestShouldMovePodToLatestNode(t *testing.T) {
+	q := New()
+	deadline := time.Now()
+
+	q.Add(getWaitingTestPod("pod-1", "uid-1"), "node-1", deadline)
+	q.Add(getWaitingTestPod("pod-1", "uid-1"), "node-2", deadline)
+
+	assert.Empty(t, q.List("node-1"))
+	assert.Equal(t, []types.UID{"uid-1"}, uids(q.List("node-2")))
+}
+
+func TestShouldRemoveWaitingPod(t *testing.T) {
+	q := New()
+	deadline := time.Now()
+
+	q.Add(getWaitingTestPod("pod-1", "uid-1"), "node-1", deadline)
+	q.Add(getWaitingTestPod("pod-2", "uid-2"), "node-1", deadline)
+	removed, ok := q.Remove("uid-1")
+	assert.True(t, ok)
+	assert.Equal(t, types.UID("uid-1"), removed.Pod.UID)
+	_, ok = q.Remove("unknown")
+	assert.False(t, ok)
+
+	assert.Equal(t, []types.UID{"uid-2"}, uids(q.List("node-1")))
+}
+
+func TestShouldListNodesWithWaitingPods(t *testing.T) {
+	q := New()
+	deadline := time.Now()
+
+	q.Add(getWaitingTestPod("pod-1", "uid-1"), "node-1", deadline)
+	q.Add(getWaitingTestPod("pod-2", "uid-2"), "node-2", deadline)
+	q.Add(getWaitingTestPod("pod-3", "uid-3"), "node-2", deadline)
+	q.Remove("uid-1")
+
+	assert.Equal(t, []string{"node-2"}, q.Nodes())
+}
+
+func TestWaitedIsLimitedByDeadline(t *testing.T) {
+	since := time.Now().Add(-time.Minute)
+
+	assert.Equal(t, 20*time.Second, WaitingPod{Since: since, Deadline: since.Add(20 * time.Second)}.Waited())
+	assert.InDelta(t, time.Minute, WaitingPod{Since: since, Deadline: since.Add(time.Hour)}.Waited(), float64(time.Second))
+}
+
+func uids(pods []WaitingPod) []types.UID {
+	ret := []types.UID{}
+	for _, p := range pods {
+		ret = append(ret, p.Pod.UID)
+	}
+	return ret
+}
+
+func withPriority(pod *v1.Pod, priority int32) *v1.Pod {
+	pod.Spec.Priority = &priority
+	return pod
+}
+
+func getWaitingTestPod(name string, uid string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: "test-namespace",
+			UID:       types.UID(uid),
+		},
+	}
+}